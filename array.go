@@ -3,7 +3,9 @@ package djson
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type Array interface {
@@ -28,9 +30,7 @@ func NewArray(items ...Value) *array {
 		CallableRegister: NewCallableRegister("array"),
 		items:            items,
 	}
-	arr.RegisterCall("map", setArray)
-	arr.RegisterCall("del", delArray)
-	arr.RegisterCall("filter", filterArray)
+	arr.registerCalls()
 	return arr
 }
 
@@ -39,15 +39,44 @@ func NewArrayWithLength(length int) *array {
 		CallableRegister: NewCallableRegister("array"),
 		items:            make([]Value, length),
 	}
+	arr.registerCalls()
+	return arr
+}
+
+func (arr *array) registerCalls() {
 	arr.RegisterCall("map", setArray)
+	arr.RegisterCall("set", setArray)
 	arr.RegisterCall("del", delArray)
 	arr.RegisterCall("filter", filterArray)
-	return arr
+	arr.RegisterCall("sort", sortArray)
+	arr.RegisterCall("reduce", reduceItems)
+	arr.RegisterCall("find", findArray)
+	arr.RegisterCall("findIndex", findIndexArray)
+	arr.RegisterCall("any", anyArray)
+	arr.RegisterCall("all", allArray)
+	arr.RegisterCall("count", countArray)
+	arr.RegisterCall("groupBy", groupByArray)
+	arr.RegisterCall("uniq", uniqArray)
+	arr.RegisterCall("uniqBy", uniqByArray)
+	arr.RegisterCall("flatten", flattenArray)
+	arr.RegisterCall("chunk", chunkArray)
+	arr.RegisterCall("zip", zipArray)
+	arr.RegisterCall("slice", sliceArray)
+	arr.RegisterCall("reverse", reverseArray)
+	arr.RegisterCall("join", joinArray)
+	arr.RegisterCall("first", firstArray)
+	arr.RegisterCall("last", lastArray)
+	arr.RegisterCall("len", lenArray)
+	arr.RegisterCall("sum", sumArray)
+	arr.RegisterCall("min", extremeArray("min", -1))
+	arr.RegisterCall("max", extremeArray("max", 1))
+	arr.RegisterCall("avg", avgArray)
+	arr.RegisterCall("fromEntries", fromEntriesArray)
 }
 
-func setArray(val Value, scanner TokenScanner, vars Context) (ret Value, err error) {
+func setArray(val Value, args Expr, ctx Context) (ret Value, err error) {
 	o := val.Value.(Array).Copy()
-	err = eachItemForSet(val, scanner, vars, func(val Value, idx int) error {
+	err = eachItemForSet(val, args, ctx, func(val Value, idx int) error {
 		o.Set(idx, val)
 		return nil
 	})
@@ -55,78 +84,709 @@ func setArray(val Value, scanner TokenScanner, vars Context) (ret Value, err err
 	return
 }
 
-func delArray(caller Value, scanner TokenScanner, vars Context) (ret Value, err error) {
-	r := caller.Value.(Array).Copy()
-	err = eachArrayItem(caller, scanner, vars, func(_ Value, idx int) error {
-		r.Del(idx)
+func delArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	r := NewArray()
+	err = eachArrayItem(caller, args, ctx, func(val Value, _ int, matched bool) error {
+		if !matched {
+			r.items = append(r.items, val)
+		}
 		return nil
 	})
 	ret = Value{Type: ValueArray, Value: r}
 	return
 }
 
-func filterArray(caller Value, scanner TokenScanner, ctx Context) (ret Value, err error) {
+func filterArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
 	no := NewArray()
-	err = eachArrayItem(caller, scanner, ctx, func(val Value, idx int) error {
-		no.items = append(no.items, val)
+	err = eachArrayItem(caller, args, ctx, func(val Value, _ int, matched bool) error {
+		if matched {
+			no.items = append(no.items, val)
+		}
 		return nil
 	})
 	ret = Value{Value: no, Type: ValueArray}
 	return
 }
 
-func eachItemForSet(o Value, scanner TokenScanner, ctx Context, handle func(val Value, idx int) error) (err error) {
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
-	resetableScanner := NewCachedTokenScanner(scanner)
-	stmt := NewStmtExecutor(resetableScanner, ctx)
+// eachItemForSet evaluate args for every item, handle the not null result
+func eachItemForSet(o Value, args Expr, ctx Context, handle func(val Value, idx int) error) (err error) {
 	ctx.pushMe(o)
 	defer ctx.popMe()
 	o.Value.(ItemEachable).Each(func(i int, val Value) bool {
-		resetableScanner.ResetRead()
-		stmt.AssignVar([]byte{'i'}, IntValue(int64(i)))
-		stmt.AssignVar([]byte{'v'}, val)
-		if err = stmt.Execute(For(NullValue())); err != nil {
+		var p Value
+		if p, err = evalArgs(args, ctx, indexVar(i), valueVar(val)); err != nil {
 			return false
 		}
-		if stmt.Exited() {
-			Exit()
-		}
-		p := stmt.Value()
 		if p.Type == ValueNull {
 			return true
 		}
-		return handle(p, i) == nil
+		err = handle(p, i)
+		return err == nil
 	})
 	return
 }
 
-func eachArrayItem(o Value, scanner TokenScanner, ctx Context, handle func(val Value, idx int) error) (err error) {
-	resetableScanner := NewCachedTokenScanner(scanner)
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
-	stmt := NewStmtExecutor(resetableScanner, ctx)
+// eachArrayItem evaluate args as a condition for every item
+func eachArrayItem(o Value, args Expr, ctx Context, handle func(val Value, idx int, matched bool) error) (err error) {
 	ctx.pushMe(o)
 	defer ctx.popMe()
 	o.Value.(ItemEachable).Each(func(i int, val Value) bool {
-		resetableScanner.ResetRead()
-		stmt.AssignVar([]byte{'i'}, IntValue(int64(i)))
-		stmt.AssignVar([]byte{'v'}, val)
-		if err = stmt.Execute(For(NullValue())); err != nil {
+		var p Value
+		if p, err = evalArgs(args, ctx, indexVar(i), valueVar(val)); err != nil {
+			return false
+		}
+		err = handle(val, i, p.Bool())
+		return err == nil
+	})
+	return
+}
+
+// eachItemValue evaluate expr for every item, and handle the item and the value of expr till
+// handle returns false
+func eachItemValue(o Value, expr Expr, ctx Context, handle func(i int, item, val Value) (bool, error)) (err error) {
+	ctx.pushMe(o)
+	defer ctx.popMe()
+	o.Value.(ItemEachable).Each(func(i int, item Value) bool {
+		var val Value
+		if val, err = evalArgs(expr, ctx, indexVar(i), valueVar(item)); err != nil {
 			return false
 		}
-		if stmt.Exited() {
-			Exit()
+		var next bool
+		next, err = handle(i, item, val)
+		return next && err == nil
+	})
+	return
+}
+
+// arrayArg evaluate the arg of the array method which isn't evaluated for every item
+func arrayArg(expr Expr, ctx Context) (ret Value, err error) {
+	if ret, err = expr.Eval(ctx); err != nil {
+		return
+	}
+	return ret.RealValue(), nil
+}
+
+func intArrayArg(method string, expr Expr, ctx Context) (n int, err error) {
+	var val Value
+	if val, err = arrayArg(expr, ctx); err != nil {
+		return
+	}
+	if val.Type != ValueInt {
+		err = fmt.Errorf("array %s expect an int, got [%s]", method, val.TypeName())
+		return
+	}
+	i, _ := val.Int()
+	return int(i), nil
+}
+
+// keysOf the values of the key expr of the items, the items themselves if no key expr
+func keysOf(caller Value, items []Expr, ctx Context) (keys []Value, err error) {
+	arr := caller.Value.(Array)
+	keys = make([]Value, 0, arr.Total())
+	if len(items) == 0 {
+		arr.Each(func(_ int, item Value) bool {
+			keys = append(keys, item.RealValue())
+			return true
+		})
+		return
+	}
+	err = eachItemValue(caller, items[0], ctx, func(_ int, _, val Value) (bool, error) {
+		keys = append(keys, val)
+		return true, nil
+	})
+	return
+}
+
+// sortArray sort(), sort(key) or sort(key, "desc"), the items are sorted stably by the keys
+func sortArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", "sort", args, 0, 2); err != nil {
+		return
+	}
+	desc := false
+	if len(items) == 2 {
+		var order Value
+		if order, err = arrayArg(items[1], ctx); err != nil {
+			return
+		}
+		switch order.String() {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			err = fmt.Errorf("array sort expect the order asc or desc, got [%s]", order.String())
+			return
+		}
+	}
+	var keys []Value
+	if keys, err = keysOf(caller, items, ctx); err != nil {
+		return
+	}
+	src := caller.Value.(Array)
+	idx := make([]int, len(keys))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		if err != nil {
+			return false
+		}
+		var c int
+		if c, err = compareKeys(keys[idx[a]], keys[idx[b]]); desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	if err != nil {
+		return
+	}
+	r := NewArrayWithLength(len(idx))
+	for i, j := range idx {
+		r.items[i] = src.Get(j)
+	}
+	return ArrayValue(r), nil
+}
+
+// compareKeys compare the keys of sort, min and max, the ints and the floats are comparable, and null is the least
+func compareKeys(a, b Value) (int, error) {
+	if a.Type == ValueNull || b.Type == ValueNull {
+		switch {
+		case a.Type == b.Type:
+			return 0, nil
+		case a.Type == ValueNull:
+			return -1, nil
+		}
+		return 1, nil
+	}
+	if a.Type != b.Type && (a.Type == ValueInt || a.Type == ValueFloat) && (b.Type == ValueInt || b.Type == ValueFloat) {
+		fa, _ := a.Float()
+		fb, _ := b.Float()
+		return FloatValue(fa).Compare(FloatValue(fb))
+	}
+	return a.Compare(b)
+}
+
+// reduceItems reduce(expr) or reduce(expr, init) of array and range, expr is evaluated for every item
+// with the accumulator acc, the first item is the initial accumulator if no init
+func reduceItems(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs(caller.TypeName(), "reduce", args, 1, 2); err != nil {
+		return
+	}
+	acc := NullValue()
+	hasInit := len(items) == 2
+	if hasInit {
+		if acc, err = arrayArg(items[1], ctx); err != nil {
+			return
+		}
+	}
+	ctx.pushMe(caller)
+	defer ctx.popMe()
+	caller.Value.(ItemEachable).Each(func(i int, item Value) bool {
+		if i == 0 && !hasInit {
+			acc = item
+			return true
+		}
+		acc, err = evalArgs(items[0], ctx, indexVar(i), valueVar(item), Variable{Name: []byte("acc"), Value: acc})
+		return err == nil
+	})
+	return acc, err
+}
+
+// findItem the first item matched the condition and its index, -1 if not found
+func findItem(method string, caller Value, args Expr, ctx Context) (idx int, item Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", method, args, 1, 1); err != nil {
+		return
+	}
+	idx, item = -1, NullValue()
+	err = eachItemValue(caller, items[0], ctx, func(i int, v, matched Value) (bool, error) {
+		if matched.Bool() {
+			idx, item = i, v
+			return false, nil
+		}
+		return true, nil
+	})
+	return
+}
+
+func findArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	_, ret, err = findItem("find", caller, args, ctx)
+	return
+}
+
+func findIndexArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var idx int
+	idx, _, err = findItem("findIndex", caller, args, ctx)
+	return IntValue(int64(idx)), err
+}
+
+func anyArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var idx int
+	idx, _, err = findItem("any", caller, args, ctx)
+	return BoolValue(idx >= 0), err
+}
+
+func allArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", "all", args, 1, 1); err != nil {
+		return
+	}
+	all := true
+	err = eachItemValue(caller, items[0], ctx, func(_ int, _, matched Value) (bool, error) {
+		all = matched.Bool()
+		return all, nil
+	})
+	return BoolValue(all), err
+}
+
+// countArray count() or count(cond), the count of the items matched the condition
+func countArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", "count", args, 0, 1); err != nil {
+		return
+	}
+	if len(items) == 0 {
+		return IntValue(int64(caller.Value.(Array).Total())), nil
+	}
+	count := 0
+	err = eachItemValue(caller, items[0], ctx, func(_ int, _, matched Value) (bool, error) {
+		if matched.Bool() {
+			count++
+		}
+		return true, nil
+	})
+	return IntValue(int64(count)), err
+}
+
+// groupByArray groupBy(key), an object of the items grouped by the keys, in the order the keys first appear
+func groupByArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", "groupBy", args, 1, 1); err != nil {
+		return
+	}
+	groups := NewObject()
+	err = eachItemValue(caller, items[0], ctx, func(_ int, item, key Value) (bool, error) {
+		k := []byte(templateString(key))
+		group, ok := groups.Get(k).Value.(*array)
+		if !ok {
+			group = NewArray()
+			groups.Set(k, ArrayValue(group))
+		}
+		group.items = append(group.items, item)
+		return true, nil
+	})
+	return ObjectValue(groups), err
+}
+
+func uniqArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	return uniqItems("uniq", caller, args, ctx)
+}
+
+func uniqByArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	return uniqItems("uniqBy", caller, args, ctx)
+}
+
+// uniqItems uniq() or uniqBy(key), the first of the items with the equal keys are kept
+func uniqItems(method string, caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", method, args, 0, 1); err != nil {
+		return
+	}
+	var keys []Value
+	if keys, err = keysOf(caller, items, ctx); err != nil {
+		return
+	}
+	src := caller.Value.(Array)
+	r := NewArray()
+	seen := make(map[string]bool, len(keys))
+	var buf bytes.Buffer
+	for i, k := range keys {
+		buf.Reset()
+		if writeUniqKey(&buf, k) {
+			if seen[buf.String()] {
+				continue
+			}
+			seen[buf.String()] = true
+		}
+		r.items = append(r.items, src.Get(i))
+	}
+	return ArrayValue(r), nil
+}
+
+// writeUniqKey write the key of val to buf, the values equal to each other have the same key,
+// false is returned if val equals nothing, such as null or an array with null in it
+func writeUniqKey(buf *bytes.Buffer, val Value) bool {
+	val = val.RealValue()
+	switch val.Type {
+	case ValueInt:
+		i, _ := val.Int()
+		buf.WriteByte('i')
+		buf.WriteString(strconv.FormatInt(i, 10))
+		buf.WriteByte(';')
+	case ValueFloat:
+		f, _ := val.Float()
+		if f == 0 {
+			f = 0 // -0 equals 0
+		}
+		buf.WriteByte('f')
+		buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		buf.WriteByte(';')
+	case ValueBool:
+		if val.Value.(Bool) {
+			buf.WriteByte('T')
+		} else {
+			buf.WriteByte('F')
+		}
+	case ValueString:
+		writeUniqBytes(buf, 's', val.Value.(String).Bytes())
+	case ValueArray:
+		arr := val.Value.(Array)
+		buf.WriteByte('a')
+		buf.WriteString(strconv.Itoa(arr.Total()))
+		buf.WriteByte(':')
+		ok := true
+		arr.Each(func(_ int, item Value) bool {
+			ok = writeUniqKey(buf, item)
+			return ok
+		})
+		return ok
+	case ValueObject:
+		obj := val.Value.(Object)
+		pairs := make([]*pair, 0, obj.Total())
+		obj.Each(func(k []byte, v Value) bool {
+			pairs = append(pairs, &pair{key: k, val: v})
+			return true
+		})
+		// the objects with the same pairs in different orders are equal
+		sort.Slice(pairs, func(i, j int) bool {
+			return bytes.Compare(pairs[i].key, pairs[j].key) < 0
+		})
+		buf.WriteByte('o')
+		buf.WriteString(strconv.Itoa(len(pairs)))
+		buf.WriteByte(':')
+		for _, p := range pairs {
+			writeUniqBytes(buf, 'k', p.key)
+			if !writeUniqKey(buf, p.val) {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func writeUniqBytes(buf *bytes.Buffer, tag byte, b []byte) {
+	buf.WriteByte(tag)
+	buf.WriteString(strconv.Itoa(len(b)))
+	buf.WriteByte(':')
+	buf.Write(b)
+}
+
+// flattenArray flatten() or flatten(depth), the nested arrays are flattened to depth, 1 by default
+func flattenArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", "flatten", args, 0, 1); err != nil {
+		return
+	}
+	depth := 1
+	if len(items) == 1 {
+		if depth, err = intArrayArg("flatten", items[0], ctx); err != nil {
+			return
 		}
-		if !stmt.value.Bool() {
+	}
+	r := NewArray()
+	var flatten func(arr Value, depth int)
+	flatten = func(arr Value, depth int) {
+		arr.Value.(ItemEachable).Each(func(_ int, item Value) bool {
+			if item = item.RealValue(); depth > 0 && (item.Type == ValueArray || item.Type == ValueRange) {
+				flatten(item, depth-1)
+			} else {
+				r.items = append(r.items, item)
+			}
 			return true
+		})
+	}
+	flatten(caller, depth)
+	return ArrayValue(r), nil
+}
+
+// chunkArray chunk(n), the items split into the arrays of n items, the last may be less
+func chunkArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", "chunk", args, 1, 1); err != nil {
+		return
+	}
+	var n int
+	if n, err = intArrayArg("chunk", items[0], ctx); err != nil {
+		return
+	}
+	if n <= 0 {
+		err = fmt.Errorf("array chunk expect a size > 0, got %d", n)
+		return
+	}
+	src, _ := itemsOf(caller)
+	r := NewArray()
+	for i := 0; i < len(src); i += n {
+		end := i + n
+		if end > len(src) {
+			end = len(src)
+		}
+		r.items = append(r.items, ArrayValue(NewArray(append([]Value{}, src[i:end]...)...)))
+	}
+	return ArrayValue(r), nil
+}
+
+// zipArray zip(arr, ...), the arrays of the items at the same index, as long as the shortest array
+func zipArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", "zip", args, 1, len(Args(args))); err != nil {
+		return
+	}
+	arrs := []Array{caller.Value.(Array)}
+	length := arrs[0].Total()
+	for _, item := range items {
+		var val Value
+		if val, err = arrayArg(item, ctx); err != nil {
+			return
 		}
-		handle(val, i)
+		if val.Type != ValueArray {
+			err = fmt.Errorf("array zip expect arrays, got [%s]", val.TypeName())
+			return
+		}
+		arr := val.Value.(Array)
+		if arr.Total() < length {
+			length = arr.Total()
+		}
+		arrs = append(arrs, arr)
+	}
+	r := NewArrayWithLength(length)
+	for i := 0; i < length; i++ {
+		tuple := NewArrayWithLength(len(arrs))
+		for j, arr := range arrs {
+			tuple.items[j] = arr.Get(i)
+		}
+		r.items[i] = ArrayValue(tuple)
+	}
+	return ArrayValue(r), nil
+}
+
+// sliceArray slice(start) or slice(start, end), end is excluded, the negatives count from the end
+func sliceArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", "slice", args, 1, 2); err != nil {
+		return
+	}
+	src, _ := itemsOf(caller)
+	bound := func(expr Expr) (int, error) {
+		n, err := intArrayArg("slice", expr, ctx)
+		if n < 0 {
+			n += len(src)
+		}
+		if n < 0 {
+			n = 0
+		} else if n > len(src) {
+			n = len(src)
+		}
+		return n, err
+	}
+	var start, end int
+	if start, err = bound(items[0]); err != nil {
+		return
+	}
+	end = len(src)
+	if len(items) == 2 {
+		if end, err = bound(items[1]); err != nil {
+			return
+		}
+	}
+	if end < start {
+		end = start
+	}
+	return ArrayValue(NewArray(append([]Value{}, src[start:end]...)...)), nil
+}
+
+func reverseArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = methodArgs("array", "reverse", args, 0, 0); err != nil {
+		return
+	}
+	src, _ := itemsOf(caller)
+	r := NewArrayWithLength(len(src))
+	for i, item := range src {
+		r.items[len(src)-1-i] = item
+	}
+	return ArrayValue(r), nil
+}
+
+// joinArray join() or join(sep), the items are joined as the strings in the templates
+func joinArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", "join", args, 0, 1); err != nil {
+		return
+	}
+	sep := ""
+	if len(items) == 1 {
+		var val Value
+		if val, err = arrayArg(items[0], ctx); err != nil {
+			return
+		}
+		if val.Type != ValueString {
+			err = fmt.Errorf("array join expect a string as the separator, got [%s]", val.TypeName())
+			return
+		}
+		sep = val.String()
+	}
+	var b strings.Builder
+	caller.Value.(Array).Each(func(i int, item Value) bool {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(templateString(item.RealValue()))
 		return true
 	})
+	return StringValue([]byte(b.String())...), nil
+}
+
+func firstArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = methodArgs("array", "first", args, 0, 0); err != nil {
+		return
+	}
+	if arr := caller.Value.(Array); arr.Total() > 0 {
+		return arr.Get(0), nil
+	}
+	return NullValue(), nil
+}
+
+func lastArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = methodArgs("array", "last", args, 0, 0); err != nil {
+		return
+	}
+	if arr := caller.Value.(Array); arr.Total() > 0 {
+		return arr.Get(arr.Total() - 1), nil
+	}
+	return NullValue(), nil
+}
+
+func lenArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = methodArgs("array", "len", args, 0, 0); err != nil {
+		return
+	}
+	return IntValue(int64(caller.Value.(Array).Total())), nil
+}
+
+// numbersOf the items or the values of the key expr of the items, which must be numbers
+func numbersOf(method string, caller Value, args Expr, ctx Context) (nums []Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("array", method, args, 0, 1); err != nil {
+		return
+	}
+	if nums, err = keysOf(caller, items, ctx); err != nil {
+		return
+	}
+	for _, n := range nums {
+		if n.Type != ValueInt && n.Type != ValueFloat {
+			err = fmt.Errorf("array %s expect numbers, got [%s]", method, n.TypeName())
+			return
+		}
+	}
 	return
 }
 
+// sumArray sum() or sum(key), the sum is an int if all the numbers are ints
+func sumArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var nums []Value
+	if nums, err = numbersOf("sum", caller, args, ctx); err != nil {
+		return
+	}
+	return sumOf(nums), nil
+}
+
+func sumOf(nums []Value) Value {
+	var isum int64
+	var fsum float64
+	float := false
+	for _, n := range nums {
+		if n.Type == ValueFloat {
+			float = true
+		}
+		i, _ := n.Int()
+		f, _ := n.Float()
+		isum += i
+		fsum += f
+	}
+	if float {
+		return FloatValue(fsum)
+	}
+	return IntValue(isum)
+}
+
+// avgArray avg() or avg(key), null if no item
+func avgArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var nums []Value
+	if nums, err = numbersOf("avg", caller, args, ctx); err != nil {
+		return
+	}
+	if len(nums) == 0 {
+		return NullValue(), nil
+	}
+	sum, _ := sumOf(nums).Float()
+	return FloatValue(sum / float64(len(nums))), nil
+}
+
+// extremeArray min() and max(), or min(key) and max(key), the least or the greatest of the keys,
+// null if no item
+func extremeArray(method string, sign int) Callback {
+	return func(caller Value, args Expr, ctx Context) (ret Value, err error) {
+		var items []Expr
+		if items, err = methodArgs("array", method, args, 0, 1); err != nil {
+			return
+		}
+		var keys []Value
+		if keys, err = keysOf(caller, items, ctx); err != nil {
+			return
+		}
+		ret = NullValue()
+		for i, k := range keys {
+			if i == 0 {
+				ret = k
+				continue
+			}
+			var c int
+			if c, err = compareKeys(k, ret); err != nil {
+				return
+			}
+			if c*sign > 0 {
+				ret = k
+			}
+		}
+		return
+	}
+}
+
+// fromEntriesArray the object of the [key, value] pairs, the inverse of the entries of object
+func fromEntriesArray(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = methodArgs("array", "fromEntries", args, 0, 0); err != nil {
+		return
+	}
+	obj := NewObject()
+	caller.Value.(Array).Each(func(i int, item Value) bool {
+		item = item.RealValue()
+		if item.Type != ValueArray || item.Value.(Array).Total() != 2 {
+			err = fmt.Errorf("array fromEntries expect the [key, value] pairs, got [%s] at %d", item.TypeName(), i)
+			return false
+		}
+		pair := item.Value.(Array)
+		if k := pair.Get(0).RealValue(); k.Type == ValueString {
+			obj.Set(k.Bytes(), pair.Get(1).RealValue())
+			return true
+		}
+		err = fmt.Errorf("array fromEntries expect a string key, got [%s] at %d", pair.Get(0).TypeName(), i)
+		return false
+	})
+	return ObjectValue(obj), err
+}
+
 func (arr *array) Add(val Value) (ret Value, err error) {
 	r := arr.Copy()
 	if val.Type != ValueArray {
@@ -227,48 +887,73 @@ func (arr *array) Append(val ...Value) {
 	arr.items = append(arr.items, val...)
 }
 
-type arrayExecutor struct {
-	scanner TokenScanner
-	vars    Context
-	value   Value
+type arrayExpr struct {
+	items []Expr
 }
 
-func newArrayExecutor(scanner TokenScanner, vs Context) *arrayExecutor {
-	return &arrayExecutor{
-		scanner: scanner,
-		vars:    vs,
+// Eval evaluate the items, [a ... b] is the range itself, so the methods of range are called on
+// it without building the array, and the ranges among the other items are spread, such as [0, 2 ... 4, 9]
+func (e *arrayExpr) Eval(ctx Context) (ret Value, err error) {
+	if len(e.items) == 1 {
+		if rg, ok := rangeOf(e.items[0]); ok {
+			return rg.Eval(ctx)
+		}
 	}
+	arr := NewArrayWithLength(0)
+	ctx.pushMe(ArrayValue(arr))
+	defer ctx.popMe()
+	var val Value
+	for _, item := range e.items {
+		if val, err = item.Eval(ctx); err != nil {
+			return
+		}
+		val = val.RealValue()
+		if _, ok := rangeOf(item); ok && val.Type == ValueRange {
+			val.Value.(ItemEachable).Each(func(_ int, v Value) bool {
+				arr.Append(v)
+				return true
+			})
+			continue
+		}
+		arr.Append(val)
+	}
+	ret = ArrayValue(arr)
+	return
 }
 
-func (e *arrayExecutor) execute() (err error) {
-	e.value, err = e.items()
+// rangeOf the rangeExpr if the item is a range, such as the item of [a ... b]
+func rangeOf(item Expr) (rg *rangeExpr, ok bool) {
+	if block, isBlock := item.(*blockExpr); isBlock && len(block.stmts) == 1 {
+		item = block.stmts[0]
+	}
+	rg, ok = item.(*rangeExpr)
 	return
 }
 
-func (e *arrayExecutor) items() (ret Value, err error) {
-	arr := NewArray()
-	e.scanner.PushEnds(TokenBracketsClose, TokenComma)
-	defer e.scanner.PopEnds(TokenBracketsClose, TokenComma)
-	e.vars.pushMe(ArrayValue(arr))
-	defer e.vars.popMe()
+// parseArray parse the items of an array till the close brackets
+func parseArray(scanner TokenScanner, opt *option) (ret Expr, err error) {
+	arr := &arrayExpr{}
+	scanner.PushEnds(TokenBracketsClose, TokenComma)
+	defer scanner.PopEnds(TokenBracketsClose, TokenComma)
 	for {
-		expr := NewStmtExecutor(e.scanner, e.vars)
-		if err = expr.Execute(); err != nil {
+		var item *blockExpr
+		if item, err = parseBlock(scanner, opt); err != nil {
 			return
 		}
-		if expr.Exited() {
-			Exit()
-		}
-		if expr.value.Type == ValueRange {
-			ret = expr.value
+		token := *scanner.Token()
+		if token.Type != TokenBracketsClose && token.Type != TokenComma {
+			err = expectError(&token, TokenBracketsClose)
 			return
 		}
-		arr.Append(expr.value)
-		if e.scanner.EndAt() == TokenBracketsClose {
+		scanner.Forward()
+		if len(item.stmts) > 0 {
+			arr.items = append(arr.items, item)
+		}
+		if token.Type == TokenBracketsClose {
 			break
 		}
 	}
-	ret = ArrayValue(arr)
+	ret = arr
 	return
 }
 
@@ -279,7 +964,7 @@ func (arr *array) lookup(k []byte) Value {
 		if err != nil {
 			return Value{Type: ValueNull}
 		}
-		if idx > len(arr.items) {
+		if idx < 0 || idx >= len(arr.items) {
 			return Value{Type: ValueNull}
 		}
 		if len(r) == 0 {
@@ -1,6 +1,9 @@
 package djson
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestArray_set(t *testing.T) {
 	// arr.set(k == 0 -> 4)
@@ -17,7 +20,11 @@ func TestArray_set(t *testing.T) {
 		{Type: TokenNumber, Raw: []byte{'4'}},
 		{Type: TokenParenthesesClose},
 	}))
-	val, err := setArray(Value{Type: ValueArray, Value: arr}, scanner, NewContext())
+	args, err := parseArgs(scanner, &option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := setArray(Value{Type: ValueArray, Value: arr}, args, NewContext())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -47,7 +54,11 @@ func TestArray_del(t *testing.T) {
 		{Type: TokenNumber, Raw: []byte{'0'}},
 		{Type: TokenParenthesesClose},
 	}))
-	newArr, err := delArray(Value{Type: ValueArray, Value: arr}, scanner, NewContext())
+	args, err := parseArgs(scanner, &option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newArr, err := delArray(Value{Type: ValueArray, Value: arr}, args, NewContext())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -69,7 +80,11 @@ func TestArray_get(t *testing.T) {
 		{Type: TokenNumber, Raw: []byte{'1'}},
 		{Type: TokenParenthesesClose},
 	}))
-	val, err := filterArray(Value{Type: ValueArray, Value: arr}, scanner, NewContext())
+	args, err := parseArgs(scanner, &option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := filterArray(Value{Type: ValueArray, Value: arr}, args, NewContext())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -80,3 +95,68 @@ func TestArray_get(t *testing.T) {
 		t.Fatal("get error")
 	}
 }
+
+func TestArray_methods(t *testing.T) {
+	users := `users = [{"name": "b", "age": 30, "team": "x"}, {"name": "a", "age": 20, "team": "y"}, {"name": "c", "age": 30, "team": "x"}];`
+	runCases(t, []stmtCase{
+		{src: users + `users.sort(v.age).map(v.name)`, val: `["a","b","c"]`},
+		{src: users + `users.sort(v.age, "desc").map(v.name)`, val: `["b","c","a"]`},
+		{src: `[3, 1, 2, 1.5, null].sort()`, val: `[null,1,1.5,2,3]`},
+		{src: `["b", "a"].sort(v, "up")`, shoulderr: true},
+		{src: `["b", 1].sort()`, shoulderr: true},
+		{src: `[1, 2, 3].reduce(acc + v)`, val: `6`},
+		{src: `[1, 2, 3].reduce(acc + v * i, 10)`, val: `18`},
+		{src: `[].reduce(acc + v)`, val: `null`},
+		{src: users + `[users.find(v.age > 25).name, users.findIndex(v.name == "c"), users.find(v.age > 99), users.findIndex(false)]`, val: `["b",2,null,-1]`},
+		{src: users + `[users.any(v.age == 20), users.all(v.age > 20), [].all(false), users.count(v.team == "x"), users.count()]`, val: `[true,false,true,2,3]`},
+		{src: users + `users.groupBy(v.team).map(v.map(v.name))`, val: `{"x":["b","c"],"y":["a"]}`},
+		{src: users + `[[1, 2, 1, 3, 2].uniq(), users.uniqBy(v.team).map(v.name)]`, val: `[[1,2,3],["b","a"]]`},
+		{src: `[1, 1.5, "1", true, 1, 1.5, "1", true].uniq()`, val: `[1,1.5,"1",true]`},
+		{src: `[{"a": 1, "b": [2]}, {"b": [2], "a": 1}, {"a": 1}, [1, {"c": 0}], [1, {"c": 0.5}], [1, {"c": 0}]].uniq()`, val: `[{"a":1,"b":[2]},{"a":1},[1,{"c":0}],[1,{"c":0.5}]]`},
+		{src: `[null, null, [null], [null]].uniq()`, val: `[null,null,[null],[null]]`},
+		{src: `[1, 2].uniqBy(v, v)`, shoulderr: true},
+		{src: `[[[1, 2], [3, [4]]].flatten(), [[1, [2, [3]]]].flatten(5)]`, val: `[[1,2,3,[4]],[1,2,3]]`},
+		{src: `[1, 2, 3, 4, 5].chunk(2)`, val: `[[1,2],[3,4],[5]]`},
+		{src: `[1].chunk(0)`, shoulderr: true},
+		{src: `[1, 2, 3].zip(["a", "b"], [true, false, true])`, val: `[[1,"a",true],[2,"b",false]]`},
+		{src: `[[1, 2, 3, 4].slice(1, 3), [1, 2, 3, 4].slice(0 - 2), [1, 2].slice(3), [1, 2, 3].slice(2, 1)]`, val: `[[2,3],[3,4],[],[]]`},
+		{src: `[1, 2, 3].reverse()`, val: `[3,2,1]`},
+		{src: `[["a", 1, 1.5, null].join("-"), [1, 2].join()]`, val: `["a-1-1.5-null","12"]`},
+		{src: `[[1, 2].first(), [1, 2].last(), [].first(), [].last(), [1, 2].len()]`, val: `[1,2,null,null,2]`},
+		{src: users + `[[1, 2].sum(), [1, 2.5].sum(), users.sum(v.age), [].sum()]`, val: `[3,3.5,80,0]`},
+		{src: users + `[users.min(v.age), users.max(v.name), [2, 1.5, 3].min(), [].max()]`, val: `[20,"c",1.5,null]`},
+		{src: `[[1, 2, 3, 4].avg(), [].avg()]`, val: `[2.5,null]`},
+		{src: `["a"].sum()`, shoulderr: true},
+		{src: `[1, 2, 3].set(v == 2 => 20)`, val: `[1,20,3]`},
+		{src: `[1].first(1)`, shoulderr: true},
+	})
+}
+
+func TestArray_uniq(t *testing.T) {
+	_, err := evalSource(`[1, 2].uniqBy(v, v)`)
+	if err == nil || !strings.Contains(err.Error(), "uniqBy") {
+		t.Fatalf("uniqBy error should name the method: %v", err)
+	}
+	val, err := evalSource(`[0 ... 49999].map(v % 100).uniq().len()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := val.MustInt(); n != 100 {
+		t.Fatalf("uniq of 50000 items got %d items, want 100", n)
+	}
+}
+
+func TestArray_lookup(t *testing.T) {
+	arr := NewArray(IntValue(1), IntValue(2))
+	for _, k := range []string{"2", "3", "-1"} {
+		if v := arr.lookup([]byte(k)); v.Type != ValueNull {
+			t.Fatalf("lookup %s of a 2 items array got [%s]", k, v.TypeName())
+		}
+	}
+	if v := arr.lookup([]byte("1")); v.MustInt() != 2 {
+		t.Fatalf("lookup 1 got %v", v)
+	}
+	runCases(t, []stmtCase{
+		{src: `a = [1, 2]; [a.2, {"a": a}.get("a.2"), {"a": a}.get("a.5", 0)]`, val: `[null,null,0]`},
+	})
+}
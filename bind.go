@@ -0,0 +1,639 @@
+package djson
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	valueType           = reflect.TypeOf(Value{})
+	timeType            = reflect.TypeOf(time.Time{})
+	numberType          = reflect.TypeOf(json.Number(""))
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Marshaler the types convert themselves to a Value
+type Marshaler interface {
+	MarshalDJSON() (Value, error)
+}
+
+// Unmarshaler the types set themselves from a Value
+type Unmarshaler interface {
+	UnmarshalDJSON(val Value) error
+}
+
+// UnmarshalError the error returned when a Value can't be stored in a go value
+type UnmarshalError struct {
+	Path  string // dotted path of the value, empty for the top-level value
+	Value string // type name of the Value
+	Type  string // the go type
+	Err   error  // the error of the Unmarshaler or the conversion, if any
+}
+
+// Error implements the error
+func (e *UnmarshalError) Error() string {
+	msg := fmt.Sprintf("can't unmarshal [%s] into [%s]", e.Value, e.Type)
+	if e.Path != "" {
+		msg += fmt.Sprintf(" at [%s]", e.Path)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap the error of the Unmarshaler or the conversion
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// ValueOf convert a go value to a Value, the fields of the structs are named by the json tags and keep
+// the order, the keys of the maps are sorted, time.Time is converted to a RFC3339 string, and the types
+// implementing Marshaler, json.Marshaler or encoding.TextMarshaler convert themselves
+func ValueOf(v interface{}) (Value, error) {
+	if v == nil {
+		return NullValue(), nil
+	}
+	return valueOf(reflect.ValueOf(v), visits{})
+}
+
+// visit a pointer, a map or a slice being converted
+type visit struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+// visits the pointers, the maps and the slices on the path being converted, one of them
+// visited again means a cycle
+type visits map[visit]bool
+
+func visitOf(rv reflect.Value) (v visit, ok bool) {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return
+		}
+		v = visit{ptr: rv.Pointer(), typ: rv.Type()}
+		if rv.Kind() == reflect.Slice {
+			v.len = rv.Len()
+		}
+		return v, true
+	}
+	return
+}
+
+func valueOf(rv reflect.Value, seen visits) (ret Value, err error) {
+	if rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return NullValue(), nil
+		}
+		return valueOf(rv.Elem(), seen)
+	}
+	t := rv.Type()
+	if rv.Kind() != reflect.Ptr && rv.CanAddr() &&
+		!t.Implements(marshalerType) && reflect.PtrTo(t).Implements(marshalerType) {
+		rv, t = rv.Addr(), reflect.PtrTo(t)
+	}
+	switch {
+	case t == valueType:
+		return rv.Interface().(Value), nil
+	case t == timeType:
+		return StringValue([]byte(rv.Interface().(time.Time).Format(time.RFC3339Nano))...), nil
+	case t == numberType:
+		return numberValue(rv.Interface().(json.Number))
+	case t.Implements(marshalerType):
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return NullValue(), nil
+		}
+		return rv.Interface().(Marshaler).MarshalDJSON()
+	case t.Implements(jsonMarshalerType):
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return NullValue(), nil
+		}
+		var data []byte
+		if data, err = rv.Interface().(json.Marshaler).MarshalJSON(); err != nil {
+			return
+		}
+		return FromJSON(data)
+	case t.Implements(textMarshalerType):
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return NullValue(), nil
+		}
+		var text []byte
+		if text, err = rv.Interface().(encoding.TextMarshaler).MarshalText(); err != nil {
+			return
+		}
+		return StringValue(text...), nil
+	}
+	if v, ok := visitOf(rv); ok {
+		if seen[v] {
+			err = fmt.Errorf("can't convert the cyclic [%s] to a djson value", t)
+			return
+		}
+		seen[v] = true
+		defer delete(seen, v)
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return IntValue(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			err = fmt.Errorf("can't convert [%s] %d to a djson int, it overflows int64", t, rv.Uint())
+			return
+		}
+		return IntValue(int64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return FloatValue(rv.Float()), nil
+	case reflect.String:
+		return StringValue([]byte(rv.String())...), nil
+	case reflect.Bool:
+		return BoolValue(rv.Bool()), nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return NullValue(), nil
+		}
+		return valueOf(rv.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return NullValue(), nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return StringValue(bytesOf(rv)...), nil
+		}
+		arr := NewArrayWithLength(rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			var item Value
+			if item, err = valueOf(rv.Index(i), seen); err != nil {
+				return
+			}
+			arr.Set(i, item)
+		}
+		return ArrayValue(arr), nil
+	case reflect.Map:
+		if !mapKeyable(t.Key()) {
+			break
+		}
+		if rv.IsNil() {
+			return NullValue(), nil
+		}
+		keys := make([]string, 0, rv.Len())
+		vals := make(map[string]reflect.Value, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := mapKeyString(iter.Key())
+			keys = append(keys, k)
+			vals[k] = iter.Value()
+		}
+		sort.Strings(keys)
+		obj := NewObject()
+		for _, k := range keys {
+			var item Value
+			if item, err = valueOf(vals[k], seen); err != nil {
+				return
+			}
+			obj.Set([]byte(k), item)
+		}
+		return ObjectValue(obj), nil
+	case reflect.Struct:
+		obj := NewObject()
+		for _, f := range fieldsOf(t) {
+			fv, ok := fieldByIndex(rv, f.index, false)
+			if !ok || f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			var item Value
+			if item, err = valueOf(fv, seen); err != nil {
+				return
+			}
+			obj.Set([]byte(f.name), item)
+		}
+		return ObjectValue(obj), nil
+	}
+	err = fmt.Errorf("can't convert [%s] to a djson value", t)
+	return
+}
+
+// Unmarshal store the Value in the go value v points to, the rules are the reverse of ValueOf,
+// the keys of the objects match the fields of the structs by the json tags or the names case
+// insensitively, and the keys without a field are ignored
+func Unmarshal(val Value, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("can't unmarshal into a non-pointer or nil [%T]", v)
+	}
+	return unmarshal(val.RealValue(), rv.Elem(), nil)
+}
+
+func unmarshal(val Value, rv reflect.Value, path []string) (err error) {
+	fail := func(e error) error {
+		return &UnmarshalError{Path: strings.Join(path, "."), Value: val.TypeName(), Type: rv.Type().String(), Err: e}
+	}
+	if val.Type == ValueNull {
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	}
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	t := rv.Type()
+	pt := reflect.PtrTo(t)
+	switch {
+	case t == valueType:
+		rv.Set(reflect.ValueOf(val))
+		return nil
+	case t == timeType:
+		if val.Type != ValueString {
+			return fail(nil)
+		}
+		var tm time.Time
+		if tm, err = time.Parse(time.RFC3339Nano, val.String()); err != nil {
+			return fail(err)
+		}
+		rv.Set(reflect.ValueOf(tm))
+		return nil
+	case pt.Implements(unmarshalerType):
+		if err = rv.Addr().Interface().(Unmarshaler).UnmarshalDJSON(val); err != nil {
+			return fail(err)
+		}
+		return nil
+	case pt.Implements(jsonUnmarshalerType):
+		var b bytes.Buffer
+		if _, err = NewJsonEncoder().Encode(val, &b); err != nil {
+			return fail(err)
+		}
+		if err = rv.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(b.Bytes()); err != nil {
+			return fail(err)
+		}
+		return nil
+	case pt.Implements(textUnmarshalerType) && val.Type == ValueString:
+		if err = rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(val.Bytes()); err != nil {
+			return fail(err)
+		}
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() > 0 {
+			return fail(nil)
+		}
+		var gv interface{}
+		if gv, err = goValueOf(val); err != nil {
+			return fail(err)
+		}
+		if gv != nil {
+			rv.Set(reflect.ValueOf(gv))
+		}
+	case reflect.Bool:
+		if val.Type != ValueBool {
+			return fail(nil)
+		}
+		rv.SetBool(val.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := integerOf(val)
+		if !ok || rv.OverflowInt(i) {
+			return fail(nil)
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := integerOf(val)
+		if !ok || i < 0 || rv.OverflowUint(uint64(i)) {
+			return fail(nil)
+		}
+		rv.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		if val.Type != ValueInt && val.Type != ValueFloat {
+			return fail(nil)
+		}
+		f, _ := val.Float()
+		if rv.OverflowFloat(f) {
+			return fail(nil)
+		}
+		rv.SetFloat(f)
+	case reflect.String:
+		if val.Type != ValueString {
+			return fail(nil)
+		}
+		rv.SetString(val.String())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && val.Type == ValueString {
+			bs := val.Bytes()
+			if rv.Kind() == reflect.Slice {
+				rv.SetBytes(append([]byte{}, bs...))
+				return nil
+			}
+			reflect.Copy(rv, reflect.ValueOf(bs))
+			return nil
+		}
+		items, ok := itemsOf(val)
+		if !ok {
+			return fail(nil)
+		}
+		if rv.Kind() == reflect.Slice {
+			rv.Set(reflect.MakeSlice(t, len(items), len(items)))
+		} else if len(items) > rv.Len() {
+			return fail(fmt.Errorf("%d items is more than the length %d", len(items), rv.Len()))
+		}
+		for i, item := range items {
+			if err = unmarshal(item.RealValue(), rv.Index(i), append(path, strconv.Itoa(i))); err != nil {
+				return
+			}
+		}
+	case reflect.Map:
+		if val.Type != ValueObject || !mapKeyable(t.Key()) {
+			return fail(nil)
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(t))
+		}
+		val.Value.(Object).Each(func(k []byte, item Value) bool {
+			key := reflect.New(t.Key()).Elem()
+			if err = setMapKey(key, string(k)); err != nil {
+				err = &UnmarshalError{Path: strings.Join(append(path, string(k)), "."), Value: "string", Type: t.Key().String(), Err: err}
+				return false
+			}
+			elem := reflect.New(t.Elem()).Elem()
+			if err = unmarshal(item.RealValue(), elem, append(path, string(k))); err != nil {
+				return false
+			}
+			rv.SetMapIndex(key, elem)
+			return true
+		})
+	case reflect.Struct:
+		if val.Type != ValueObject {
+			return fail(nil)
+		}
+		fields := fieldsOf(t)
+		val.Value.(Object).Each(func(k []byte, item Value) bool {
+			f := fieldNamed(fields, string(k))
+			if f == nil {
+				return true
+			}
+			fv, ok := fieldByIndex(rv, f.index, true)
+			if !ok {
+				return true
+			}
+			err = unmarshal(item.RealValue(), fv, append(path, string(k)))
+			return err == nil
+		})
+	default:
+		return fail(nil)
+	}
+	return
+}
+
+// goValueOf convert the Value to the go value an interface{} holds, such as map[string]interface{}
+func goValueOf(val Value) (ret interface{}, err error) {
+	val = val.RealValue()
+	switch val.Type {
+	case ValueNull:
+		return nil, nil
+	case ValueBool:
+		return val.Bool(), nil
+	case ValueInt:
+		return val.Int()
+	case ValueFloat:
+		return val.Float()
+	case ValueString:
+		return val.String(), nil
+	case ValueObject:
+		m := make(map[string]interface{}, val.Value.(Object).Total())
+		val.Value.(Object).Each(func(k []byte, item Value) bool {
+			m[string(k)], err = goValueOf(item)
+			return err == nil
+		})
+		return m, err
+	case ValueArray, ValueRange:
+		items, _ := itemsOf(val)
+		s := make([]interface{}, len(items))
+		for i, item := range items {
+			if s[i], err = goValueOf(item); err != nil {
+				return
+			}
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("[%s] has no go value", val.TypeName())
+}
+
+func numberValue(n json.Number) (Value, error) {
+	if i, err := n.Int64(); err == nil {
+		return IntValue(i), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return NullValue(), err
+	}
+	return FloatValue(f), nil
+}
+
+func bytesOf(rv reflect.Value) []byte {
+	if rv.Kind() == reflect.Slice {
+		return append([]byte{}, rv.Bytes()...)
+	}
+	bs := make([]byte, rv.Len())
+	reflect.Copy(reflect.ValueOf(bs), rv)
+	return bs
+}
+
+// integerOf the int of the Value, the floats without the fraction are integers too
+func integerOf(val Value) (int64, bool) {
+	switch val.Type {
+	case ValueInt:
+		i, err := val.Int()
+		return i, err == nil
+	case ValueFloat:
+		f, _ := val.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+	return 0, false
+}
+
+// itemsOf the items of an array or a range
+func itemsOf(val Value) (items []Value, ok bool) {
+	if val.Type != ValueArray && val.Type != ValueRange {
+		return nil, false
+	}
+	val.Value.(ItemEachable).Each(func(_ int, item Value) bool {
+		items = append(items, item)
+		return true
+	})
+	return items, true
+}
+
+func mapKeyable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func mapKeyString(k reflect.Value) string {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	return strconv.FormatInt(k.Int(), 10)
+}
+
+func setMapKey(key reflect.Value, k string) error {
+	switch key.Kind() {
+	case reflect.String:
+		key.SetString(k)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(k, 10, 64)
+		if err != nil || key.OverflowUint(u) {
+			return errors.New("invalid key")
+		}
+		key.SetUint(u)
+	default:
+		i, err := strconv.ParseInt(k, 10, 64)
+		if err != nil || key.OverflowInt(i) {
+			return errors.New("invalid key")
+		}
+		key.SetInt(i)
+	}
+	return nil
+}
+
+// field a field of a struct, the fields of the embedded structs are promoted like encoding/json
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	tagged    bool
+}
+
+func fieldsOf(t reflect.Type) []field {
+	var fields []field
+	collectFields(t, nil, &fields)
+	// the shallower field wins when the names conflict, and the tagged one if they are as deep
+	byName := make(map[string]int, len(fields))
+	ret := make([]field, 0, len(fields))
+	for _, f := range fields {
+		i, ok := byName[f.name]
+		if !ok {
+			byName[f.name] = len(ret)
+			ret = append(ret, f)
+			continue
+		}
+		if prev := ret[i]; len(f.index) < len(prev.index) || len(f.index) == len(prev.index) && f.tagged && !prev.tagged {
+			ret[i] = f
+		}
+	}
+	return ret
+}
+
+func collectFields(t reflect.Type, index []int, fields *[]field) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if at := strings.IndexByte(tag, ','); at >= 0 {
+			name, opts = tag[:at], tag[at+1:]
+		}
+		idx := append(append([]int{}, index...), i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			collectFields(ft, idx, fields)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		f := field{name: name, index: idx, tagged: name != ""}
+		if name == "" {
+			f.name = sf.Name
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			if opt == "omitempty" {
+				f.omitEmpty = true
+			}
+		}
+		*fields = append(*fields, f)
+	}
+}
+
+// fieldNamed the field named exactly name, or case insensitively
+func fieldNamed(fields []field, name string) *field {
+	var fold *field
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+		if fold == nil && strings.EqualFold(fields[i].name, name) {
+			fold = &fields[i]
+		}
+	}
+	return fold
+}
+
+// fieldByIndex the field of the struct by the index, the nil embedded pointers are allocated if alloc,
+// otherwise ok is false
+func fieldByIndex(rv reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, at := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !alloc || !rv.CanSet() {
+					return rv, false
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(at)
+	}
+	return rv, true
+}
+
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package djson
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindLevel int
+
+func (l bindLevel) MarshalDJSON() (Value, error) {
+	return StringValue([]byte([]string{"low", "high"}[l])...), nil
+}
+
+func (l *bindLevel) UnmarshalDJSON(val Value) error {
+	switch val.String() {
+	case "low":
+		*l = 0
+	case "high":
+		*l = 1
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+type bindBase struct {
+	ID      int       `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+type bindServer struct {
+	bindBase
+	Name    string            `json:"name"`
+	Port    uint16            `json:"port,omitempty"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Level   bindLevel         `json:"level"`
+	Backup  *bindServer       `json:"backup,omitempty"`
+	Extra   interface{}       `json:"extra,omitempty"`
+	Ignored string            `json:"-"`
+	secret  string
+}
+
+func TestValueOf(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	val, err := ValueOf(&bindServer{
+		bindBase: bindBase{ID: 1, Created: created},
+		Name:     "web",
+		Tags:     []string{"a"},
+		Level:    1,
+		Backup:   &bindServer{Name: "backup", Port: 81},
+		Ignored:  "x",
+		secret:   "s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := val.Value.(Object)
+	var keys []string
+	obj.Each(func(k []byte, _ Value) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+	if strings.Join(keys, ",") != "id,created,name,tags,level,backup" {
+		t.Fatalf("fields error: %v", keys)
+	}
+	if obj.Get([]byte("created")).String() != "2024-01-02T03:04:05Z" || obj.Get([]byte("level")).String() != "high" {
+		t.Fatal("time or marshaler error")
+	}
+	if obj.Get([]byte("backup")).Value.(Object).Get([]byte("port")).MustInt() != 81 {
+		t.Fatal("pointer error")
+	}
+	if _, err = ValueOf(map[bool]int{true: 1}); err == nil {
+		t.Fatal("map with bool keys should fail")
+	}
+}
+
+func TestValueOf_invalid(t *testing.T) {
+	cyclic := &bindServer{Name: "a"}
+	cyclic.Backup = cyclic
+	loop := map[string]interface{}{}
+	loop["self"] = loop
+	list := []interface{}{1}
+	list[0] = list
+	for i, v := range []interface{}{uint64(math.MaxUint64), uint64(1 << 63), cyclic, loop, list} {
+		if _, err := ValueOf(v); err == nil {
+			t.Fatalf("%d: error should occur", i)
+		}
+	}
+	// the same pointer twice isn't a cycle
+	shared := &bindServer{Name: "b"}
+	val, err := ValueOf([]*bindServer{shared, shared, {Backup: shared}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.Value.(Array).Total() != 3 {
+		t.Fatal("shared pointer error")
+	}
+	if val, err = ValueOf(uint64(math.MaxInt64)); err != nil || val.MustInt() != math.MaxInt64 {
+		t.Fatalf("max uint64 in int64 error: %v", err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`{
+    "id": 7,
+    "created": "2024-01-02T03:04:05Z",
+    "Name": "web",
+    "port": 8080,
+    "tags": ["a", "b"],
+    "labels": {"env": "prod"},
+    "level": "high",
+    "backup": {"name": "backup", "port": 81.0},
+    "extra": {"n": [1 ... 3], "f": 1.5},
+    "unknown": true
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := prog.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s bindServer
+	if err = Unmarshal(val, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != 7 || !s.Created.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) || s.Name != "web" || s.Port != 8080 {
+		t.Fatalf("fields error: %+v", s)
+	}
+	if len(s.Tags) != 2 || s.Labels["env"] != "prod" || s.Level != 1 || s.Backup == nil || s.Backup.Port != 81 {
+		t.Fatalf("collections error: %+v", s)
+	}
+	extra := s.Extra.(map[string]interface{})
+	if len(extra["n"].([]interface{})) != 2 || extra["f"].(float64) != 1.5 {
+		t.Fatalf("interface error: %+v", extra)
+	}
+	data := []struct {
+		src  string
+		path string
+	}{
+		{src: `{"port": 70000}`, path: "port"},
+		{src: `{"tags": ["a", 1]}`, path: "tags.1"},
+		{src: `{"backup": {"level": "middle"}}`, path: "backup.level"},
+		{src: `{"created": "yesterday"}`, path: "created"},
+	}
+	for _, item := range data {
+		val, _ := FromJSON([]byte(item.src))
+		var ue *UnmarshalError
+		if err = Unmarshal(val, &bindServer{}); !errors.As(err, &ue) || ue.Path != item.path {
+			t.Fatalf("%s should fail at %s: %v", item.src, item.path, err)
+		}
+	}
+	if err = Unmarshal(val, s); err == nil {
+		t.Fatal("non-pointer should fail")
+	}
+}
@@ -1,35 +1,49 @@
 package djson
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
 
 type Callable interface {
-	call(k string, caller Value, scanner TokenScanner, vars Context) (Value, error)
+	call(k string, caller Value, args Expr, vars Context) (Value, error)
 }
 
 type CallableRegister struct {
-	calls map[string]Callback
+	calls map[string]ContextCallback
 	typ   string
 }
 
-type Callback func(caller Value, scanner TokenScanner, vars Context) (Value, error)
+// Callback a native function, args is the syntax tree node inside the parentheses of the call,
+// the callback decides how many times and within which Context to evaluate it
+type Callback func(caller Value, args Expr, vars Context) (Value, error)
+
+// ContextCallback a Callback receives the context.Context of the evaluation, the callback doing
+// the io, such as a http request, should stop once goctx is done
+type ContextCallback func(goctx context.Context, caller Value, args Expr, vars Context) (Value, error)
 
 func NewCallableRegister(typ string) *CallableRegister {
-	return &CallableRegister{typ: typ, calls: map[string]Callback{
-		"if": ifCall,
-	}}
+	c := &CallableRegister{typ: typ}
+	c.RegisterCall("if", ifCall)
+	return c
 }
 
 func (c *CallableRegister) RegisterCall(k string, ck Callback) {
+	c.RegisterContextCall(k, func(_ context.Context, caller Value, args Expr, vars Context) (Value, error) {
+		return ck(caller, args, vars)
+	})
+}
+
+// RegisterContextCall register a ContextCallback
+func (c *CallableRegister) RegisterContextCall(k string, ck ContextCallback) {
 	if c.calls == nil {
-		c.calls = make(map[string]Callback)
+		c.calls = make(map[string]ContextCallback)
 	}
 	c.calls[k] = ck
 }
 
-func (c *CallableRegister) call(k string, caller Value, scanner TokenScanner, vars Context) (val Value, err error) {
+func (c *CallableRegister) call(k string, caller Value, args Expr, vars Context) (val Value, err error) {
 	call, ok := c.calls[k]
 	if !ok {
 		call, ok = c.caseInsensitiveCallback(k)
@@ -38,10 +52,10 @@ func (c *CallableRegister) call(k string, caller Value, scanner TokenScanner, va
 		err = fmt.Errorf("undefined method [%s] for %s", k, c.typ)
 		return
 	}
-	return call(caller, scanner, vars)
+	return call(vars.evalState().context(), caller, args, vars)
 }
 
-func (c *CallableRegister) caseInsensitiveCallback(k string) (Callback, bool) {
+func (c *CallableRegister) caseInsensitiveCallback(k string) (ContextCallback, bool) {
 	for ck, c := range c.calls {
 		if strings.EqualFold(ck, k) {
 			return c, true
@@ -50,21 +64,59 @@ func (c *CallableRegister) caseInsensitiveCallback(k string) (Callback, bool) {
 	return nil, false
 }
 
-func ifCall(val Value, scanner TokenScanner, ctx Context) (ret Value, err error) {
+func ifCall(val Value, args Expr, ctx Context) (ret Value, err error) {
 	ctx.pushMe(val)
 	defer ctx.popMe()
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
-	expr := NewStmtExecutor(scanner, ctx)
-	if err = expr.Execute(For(NullValue())); err != nil {
+	if ret, err = args.Eval(ctx); err != nil {
 		return
 	}
-	if expr.Exited() {
-		Exit()
-	}
-	if ret = expr.Value(); ret.Type != ValueNull {
+	if ret = ret.RealValue(); ret.Type != ValueNull {
 		return
 	}
 	ret = val
 	return
 }
+
+// methodArgs split the args of the method of typ, the count of the args must be in [least, most]
+func methodArgs(typ, method string, args Expr, least, most int) (items []Expr, err error) {
+	items = Args(args)
+	if len(items) >= least && len(items) <= most {
+		return
+	}
+	if least == most {
+		err = fmt.Errorf("%s %s expect %d args, got %d", typ, method, least, len(items))
+	} else {
+		err = fmt.Errorf("%s %s expect %d to %d args, got %d", typ, method, least, most, len(items))
+	}
+	return
+}
+
+// evalArgs evaluate args in a new scope which the vars declared in, it's called for each item
+// in the Each loops of the array, object and range, so the loops stop once the evaluation canceled
+func evalArgs(args Expr, ctx Context, vars ...Variable) (ret Value, err error) {
+	if err = ctx.evalState().limiter.step(); err != nil {
+		return
+	}
+	ctx.PushScope()
+	defer ctx.PopScope()
+	for _, v := range vars {
+		ctx.Declare(v.Name, v.Value)
+	}
+	if ret, err = args.Eval(ctx); err != nil {
+		return
+	}
+	ret = ret.RealValue()
+	return
+}
+
+func indexVar(i int) Variable {
+	return Variable{Name: []byte{'i'}, Value: IntValue(int64(i))}
+}
+
+func keyVar(k []byte) Variable {
+	return Variable{Name: []byte{'k'}, Value: StringValue(k...)}
+}
+
+func valueVar(val Value) Variable {
+	return Variable{Name: []byte{'v'}, Value: val}
+}
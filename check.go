@@ -0,0 +1,42 @@
+package djson
+
+import (
+	"bytes"
+	"io"
+)
+
+// Diagnostic a problem Check found in the djson source
+type Diagnostic struct {
+	*Error
+}
+
+// String prints the position and the cause followed by an excerpt of the source
+func (d Diagnostic) String() string {
+	return d.Error.Error()
+}
+
+// Check parse the djson source in the recovery mode, which skips to the next semicolon,
+// comma or closing bracket after an error and goes on, so all the errors can be found in one run
+func Check(r io.Reader, opts ...StmtOption) []Diagnostic {
+	opt := &option{}
+	for _, apply := range opts {
+		apply(opt)
+	}
+	opt.recovery = true
+	var src bytes.Buffer
+	_, err := parseBlock(NewTokenScanner(NewLexer(io.TeeReader(r, &src), defaultBufSize)), opt)
+	ret := make([]Diagnostic, 0, len(opt.diagnostics)+1)
+	for i, e := range opt.diagnostics {
+		// the errors at the same position are reported once
+		if i > 0 && e.Row == opt.diagnostics[i-1].Row && e.Col == opt.diagnostics[i-1].Col {
+			continue
+		}
+		locate(e, opt.file, src.Bytes())
+		ret = append(ret, Diagnostic{e})
+	}
+	if err != nil {
+		// the errors can't be recovered from, such as failing to read the source
+		ret = append(ret, Diagnostic{&Error{File: opt.file, Category: ErrLex, Err: err}})
+	}
+	return ret
+}
@@ -0,0 +1,28 @@
+package djson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	ds := Check(strings.NewReader("a = 1 +;\nb = @;\nc = {\"a\": 1, \"b\" 2};\nd = [1, ) 2];\ne = 3"), Filename("check.djson"))
+	expects := [][2]int{{1, 8}, {2, 5}, {3, 19}, {4, 9}}
+	if len(ds) != len(expects) {
+		for _, d := range ds {
+			t.Log(d)
+		}
+		t.Fatalf("diagnostics total error: %d", len(ds))
+	}
+	for i, d := range ds {
+		if d.Row != expects[i][0] || d.Col != expects[i][1] || d.File != "check.djson" {
+			t.Fatalf("diagnostic %d position error: %s", i, d)
+		}
+	}
+	if ds[1].Category != ErrLex || ds[0].Category != ErrParse {
+		t.Fatal("diagnostic category error")
+	}
+	if len(Check(strings.NewReader(`a = [1, 2]; b = {"a": a}`))) != 0 {
+		t.Fatal("valid source should have no diagnostic")
+	}
+}
@@ -4,14 +4,19 @@ import "bytes"
 
 type Context interface {
 	Assign(varName []byte, val Value)
+	Declare(varName []byte, val Value)
+	RegisterModule(name string, c Callable)
 	ValueOf(name []byte) Value
 	PushScope()
 	PopScope()
 	Merge(ctx Context)
 	All() []Variable
 	Copy() Context
+	fork() Context
 	pushMe(val Value)
 	popMe()
+	evalState() *evalState
+	isolated() *ctx
 }
 
 type Variable struct {
@@ -26,6 +31,7 @@ type scope struct {
 
 type ctx struct {
 	scope *scope
+	state evalState
 }
 
 var _ Context = &ctx{}
@@ -54,8 +60,10 @@ func (s *scope) copy() *scope {
 		p:    p,
 		vars: make([]Variable, len(s.vars)),
 	}
-	copy(r.vars, s.vars)
-	return s
+	for i, v := range s.vars {
+		r.vars[i] = Variable{Name: v.Name, Value: deepCopy(v.Value)}
+	}
+	return r
 }
 
 func (s *scope) indexOf(name []byte) int {
@@ -83,6 +91,21 @@ func (v *ctx) Assign(name []byte, val Value) {
 	v.scope.assign(name, val)
 }
 
+// Declare a variable in the current scope, it shadows the variable with the same name in the parent scopes
+func (v *ctx) Declare(name []byte, val Value) {
+	v.scope.assign(name, val)
+}
+
+// RegisterModule declare the Callable as a variable in the outermost scope, so it can be
+// reached from everywhere, such as _http.get(req)
+func (v *ctx) RegisterModule(name string, c Callable) {
+	scope := v.scope
+	for scope.p != nil {
+		scope = scope.p
+	}
+	scope.assign([]byte(name), CallableValue(c))
+}
+
 func (ctx *ctx) Merge(lv Context) {
 	all := lv.All()
 	for _, v := range all {
@@ -113,8 +136,16 @@ func (v *ctx) All() []Variable {
 	return ret
 }
 
+// Copy new a Context with all the scopes and the objects and the arrays in them copied, the variables
+// assigned or changed in either don't affect the other
 func (v *ctx) Copy() Context {
-	return &ctx{scope: v.scope.copy()}
+	return &ctx{scope: v.scope.copy(), state: v.state}
+}
+
+// fork new a Context sharing the scopes of v, the variables assigned to either are seen by both,
+// but the scopes pushed and the state aren't shared
+func (v *ctx) fork() Context {
+	return &ctx{scope: v.scope, state: v.state}
 }
 
 func (v *ctx) PushScope() {
@@ -138,6 +169,22 @@ func (v *ctx) ValueOf(name []byte) Value {
 	return NullValue()
 }
 
+// isolated new a Context with the outermost scope copied, such as the modules registered and the variables
+// preseeded, but none of the variables declared by the evaluation
+func (v *ctx) isolated() *ctx {
+	s := v.scope
+	for s.p != nil {
+		s = s.p
+	}
+	vars := make([]Variable, len(s.vars))
+	copy(vars, s.vars)
+	return &ctx{scope: &scope{vars: vars}, state: v.state}
+}
+
+func (v *ctx) evalState() *evalState {
+	return &v.state
+}
+
 func (v *ctx) pushMe(val Value) {
 	mk := []byte{'_', 'm', 'e'}
 	if idx := v.scope.indexOf(mk); idx > -1 {
@@ -0,0 +1,165 @@
+package djson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCategory the category of an Error
+type ErrorCategory int
+
+const (
+	ErrLex     = ErrorCategory(iota) // the source can't be split into tokens
+	ErrParse                         // the tokens can't be parsed to a syntax tree
+	ErrType                          // the operation doesn't support the type of the values
+	ErrRuntime                       // the evaluation failed for other reasons
+)
+
+// String get the name of the category
+func (c ErrorCategory) String() string {
+	return map[ErrorCategory]string{
+		ErrLex:     "lex",
+		ErrParse:   "parse",
+		ErrType:    "type",
+		ErrRuntime: "runtime",
+	}[c]
+}
+
+// Error an error located in the djson source
+type Error struct {
+	File     string        // name of the source file, empty if unknown
+	Row, Col int           // position of the error, begin with 1, 0 if unknown
+	Span     int           // length of the source which causes the error
+	Category ErrorCategory // category of the error
+	Err      error         // the cause
+	Line     []byte        // the source line at Row, used to print the excerpt
+}
+
+// Error implements the error, prints the position and the cause followed by an excerpt of the source
+func (e *Error) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		b.WriteByte(':')
+	}
+	if e.Row > 0 {
+		fmt.Fprintf(&b, "%d:%d: ", e.Row, e.Col)
+	} else if e.File != "" {
+		b.WriteByte(' ')
+	}
+	fmt.Fprintf(&b, "%s error: %v", e.Category, e.Err)
+	if e.Row > 0 && e.Line != nil {
+		b.WriteByte('\n')
+		b.WriteString(e.Excerpt())
+	}
+	return b.String()
+}
+
+// Unwrap get the cause of the Error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Excerpt get the source line with a caret under the error
+func (e *Error) Excerpt() string {
+	line := bytes.TrimRight(e.Line, "\r\n")
+	row := fmt.Sprintf("%d", e.Row)
+	var b strings.Builder
+	fmt.Fprintf(&b, " %s | %s\n", row, line)
+	fmt.Fprintf(&b, " %s | ", strings.Repeat(" ", len(row)))
+	col := e.Col - 1
+	if col > len(line) {
+		col = len(line)
+	}
+	for _, r := range string(line[:col]) {
+		// keep the tabs so the caret is aligned with the line
+		if r == '\t' {
+			b.WriteByte('\t')
+			continue
+		}
+		b.WriteString(strings.Repeat(" ", runeWidth(r)))
+	}
+	end := col + e.Span
+	if end > len(line) {
+		end = len(line)
+	}
+	span := 0
+	for _, r := range string(line[col:end]) {
+		span += runeWidth(r)
+	}
+	if span < 1 {
+		span = 1
+	}
+	b.WriteString(strings.Repeat("^", span))
+	return b.String()
+}
+
+// runeWidth the columns a rune takes in the terminal, the east asian wide runes take 2
+func runeWidth(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6:
+		return 2
+	}
+	return 1
+}
+
+// pos the position of a node in the source
+type pos struct {
+	row, col, span int
+}
+
+func posOf(token *Token) pos {
+	return pos{row: token.Row, col: token.Col, span: token.Span}
+}
+
+func newError(cat ErrorCategory, p pos, err error) *Error {
+	return &Error{Category: cat, Row: p.row, Col: p.col, Span: p.span, Err: err}
+}
+
+// wrapError locate the err at p if it hasn't been located yet
+func wrapError(cat ErrorCategory, p pos, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return newError(cat, p, err)
+}
+
+// locate complete the file and the source line of the Errors in err
+func locate(err error, file string, src []byte) {
+	var e *Error
+	if !errors.As(err, &e) || e.Line != nil {
+		return
+	}
+	if e.File == "" {
+		e.File = file
+	}
+	if e.Row > 0 {
+		e.Line = sourceLine(src, e.Row)
+	}
+}
+
+func sourceLine(src []byte, row int) []byte {
+	for i := 1; i < row; i++ {
+		idx := bytes.IndexByte(src, '\n')
+		if idx < 0 {
+			return nil
+		}
+		src = src[idx+1:]
+	}
+	if idx := bytes.IndexByte(src, '\n'); idx >= 0 {
+		src = src[:idx]
+	}
+	return src
+}
@@ -0,0 +1,59 @@
+package djson
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestError_lex(t *testing.T) {
+	_, err := Compile(strings.NewReader("a = 1;\nb = @;"), Filename("lex.djson"))
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("error type error: %v", err)
+	}
+	if !(e.Category == ErrLex && e.File == "lex.djson" && e.Row == 2 && e.Col == 5) {
+		t.Fatalf("error position error: %v", err)
+	}
+	if err.Error() != "lex.djson:2:5: lex error: unexpected char [@]\n 2 | b = @;\n   |     ^" {
+		t.Fatalf("error message error: %s", err)
+	}
+}
+
+func TestError_parse(t *testing.T) {
+	_, err := Compile(strings.NewReader(`{"a": 1, "b" 2}`))
+	var e *Error
+	if !(errors.As(err, &e) && e.Category == ErrParse && e.Row == 1 && e.Col == 15) {
+		t.Fatalf("parse error error: %v", err)
+	}
+}
+
+func TestError_type(t *testing.T) {
+	prog, err := Compile(strings.NewReader("a = \"hello\";\nb = a * 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = prog.Eval(nil)
+	var e *Error
+	if !(errors.As(err, &e) && e.Category == ErrType && e.Row == 2 && e.Col == 7) {
+		t.Fatalf("type error error: %v", err)
+	}
+	if !strings.HasSuffix(err.Error(), " 2 | b = a * 2\n   |       ^") {
+		t.Fatalf("excerpt error: %s", err)
+	}
+	if errors.Unwrap(err) == nil {
+		t.Fatal("cause should be wrapped")
+	}
+}
+
+func TestError_runtime(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`[1, 2].map(v.foo(1))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = prog.Eval(nil)
+	var e *Error
+	if !(errors.As(err, &e) && e.Category == ErrRuntime && e.Col == 14 && e.Span == 3) {
+		t.Fatalf("runtime error error: %v", err)
+	}
+}
@@ -0,0 +1,387 @@
+package djson
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Expr a node of the syntax tree which the parser built from the token stream,
+// a tree is immutable after built, so it can be evaluated many times and from many goroutines
+type Expr interface {
+	// Eval evaluate the node within the Context
+	Eval(ctx Context) (Value, error)
+}
+
+type literalExpr struct {
+	val Value
+}
+
+func (e *literalExpr) Eval(ctx Context) (Value, error) {
+	return e.val, nil
+}
+
+type identExpr struct {
+	pos
+	name []byte
+}
+
+func (e *identExpr) Eval(ctx Context) (Value, error) {
+	return Value{Type: ValueIdentifier, Value: &identifier{
+		name: e.name,
+		vars: ctx.fork(),
+	}}, nil
+}
+
+type memberExpr struct {
+	pos
+	left Expr
+	name []byte
+}
+
+func (e *memberExpr) Eval(ctx Context) (ret Value, err error) {
+	var p Value
+	if p, err = e.left.Eval(ctx); err != nil {
+		return
+	}
+	ret = Value{Type: ValueIdentifier, Value: &identifier{
+		name: e.name,
+		p:    p,
+		vars: ctx.fork(),
+	}}
+	return
+}
+
+// holeName the variable holeExpr refers to, it can't be an identifier in djson
+var holeName = []byte("?.")
+
+// holeExpr the value on the left of ?. in the chain of optionalExpr
+type holeExpr struct{}
+
+func (e *holeExpr) Eval(ctx Context) (Value, error) {
+	return ctx.ValueOf(holeName), nil
+}
+
+// optionalExpr left?.chain, the chain is built on a holeExpr, which is the value of left
+type optionalExpr struct {
+	left  Expr
+	chain Expr
+}
+
+func (e *optionalExpr) Eval(ctx Context) (ret Value, err error) {
+	var left Value
+	if left, err = e.left.Eval(ctx); err != nil {
+		return
+	}
+	if left = left.RealValue(); left.Type == ValueNull {
+		return NullValue(), nil
+	}
+	ctx.PushScope()
+	defer ctx.PopScope()
+	ctx.Declare(holeName, left)
+	if ret, err = e.chain.Eval(ctx); err != nil {
+		return
+	}
+	return ret.RealValue(), nil
+}
+
+type callExpr struct {
+	pos
+	fn   Expr
+	args Expr
+}
+
+func (e *callExpr) Eval(ctx Context) (ret Value, err error) {
+	var fn Value
+	if fn, err = e.fn.Eval(ctx); err != nil {
+		return
+	}
+	if fn.Type != ValueIdentifier {
+		err = newError(ErrRuntime, e.pos, fmt.Errorf("can't call on a [%s]", fn.TypeName()))
+		return
+	}
+	ret, err = fn.Value.(Identifier).Call(e.args, ctx)
+	err = wrapError(ErrRuntime, e.pos, err)
+	return
+}
+
+type assignExpr struct {
+	pos
+	left  Expr
+	right Expr
+}
+
+func (e *assignExpr) Eval(ctx Context) (ret Value, err error) {
+	var left Value
+	if left, err = e.left.Eval(ctx); err != nil {
+		return
+	}
+	if left.Type != ValueIdentifier {
+		err = newError(ErrRuntime, e.pos, errors.New("only identifier can assign to"))
+		return
+	}
+	if ret, err = e.right.Eval(ctx); err != nil {
+		return
+	}
+	ret = ret.RealValue()
+	err = wrapError(ErrRuntime, e.pos, left.Value.(Identifier).Assign(ret))
+	return
+}
+
+type reductionExpr struct {
+	cond Expr
+	val  Expr
+}
+
+func (e *reductionExpr) Eval(ctx Context) (ret Value, err error) {
+	var cond Value
+	if cond, err = e.cond.Eval(ctx); err != nil || !cond.Bool() {
+		return
+	}
+	return e.val.Eval(ctx)
+}
+
+type binaryExpr struct {
+	pos
+	op          TokenType
+	left, right Expr
+}
+
+func (e *binaryExpr) Eval(ctx Context) (ret Value, err error) {
+	var left, right Value
+	if left, err = e.left.Eval(ctx); err != nil {
+		return
+	}
+	// short circuit for the logic operators
+	switch e.op {
+	case TokenCoalesce:
+		if left = left.RealValue(); left.Type != ValueNull {
+			return left, nil
+		}
+		if right, err = e.right.Eval(ctx); err != nil {
+			return
+		}
+		return right.RealValue(), nil
+	case TokenOr:
+		if left.Bool() {
+			return BoolValue(true), nil
+		}
+	case TokenAnd:
+		if !left.Bool() {
+			return BoolValue(false), nil
+		}
+	}
+	if right, err = e.right.Eval(ctx); err != nil {
+		return
+	}
+	switch e.op {
+	case TokenOr:
+		ret = left.Or(right)
+	case TokenAnd:
+		ret = left.And(right)
+	case TokenEqual:
+		ret = BoolValue(left.Equal(right))
+	case TokenNotEqual:
+		ret = BoolValue(!left.Equal(right))
+	case TokenGreateThan, TokenGreateThanEqual, TokenLessThan, TokenLessThanEqual:
+		var com int
+		if com, err = left.Compare(right); err != nil {
+			return
+		}
+		switch e.op {
+		case TokenGreateThan:
+			ret = BoolValue(com > 0)
+		case TokenGreateThanEqual:
+			ret = BoolValue(com >= 0)
+		case TokenLessThan:
+			ret = BoolValue(com < 0)
+		case TokenLessThanEqual:
+			ret = BoolValue(com <= 0)
+		}
+	case TokenAddition:
+		ret, err = left.Add(right)
+	case TokenMinus:
+		ret, err = left.Minus(right)
+	case TokenMultiplication:
+		ret, err = left.Multiply(right)
+	case TokenDevision:
+		ret, err = left.Devide(right)
+	case TokenMod:
+		ret, err = left.Mod(right)
+	}
+	err = wrapError(ErrType, e.pos, err)
+	return
+}
+
+// rangeExpr begin ... end, begin ..= end and the step after the colon, the range counts down if
+// end is less than begin
+type rangeExpr struct {
+	pos
+	begin, end, step Expr
+	inclusive        bool
+}
+
+func (e *rangeExpr) Eval(ctx Context) (ret Value, err error) {
+	var begin, end int64
+	step := int64(1)
+	if begin, err = e.bound(e.begin, ctx); err != nil {
+		err = wrapError(ErrType, e.pos, fmt.Errorf("can't convert to int for range begin: %w", err))
+		return
+	}
+	if end, err = e.bound(e.end, ctx); err != nil {
+		err = wrapError(ErrType, e.pos, fmt.Errorf("can't convert to int for range end: %w", err))
+		return
+	}
+	if e.step != nil {
+		if step, err = e.bound(e.step, ctx); err != nil {
+			err = wrapError(ErrType, e.pos, fmt.Errorf("can't convert to int for range step: %w", err))
+			return
+		}
+		if step <= 0 {
+			err = newError(ErrRuntime, e.pos, fmt.Errorf("range step must be greater than 0, got %d", step))
+			return
+		}
+	}
+	rg := newRange(int(begin), int(end), int(step), e.inclusive)
+	if n, ok := rg.length(); !ok {
+		err = newError(ErrRuntime, e.pos, fmt.Errorf("range length %d overflows int", n))
+		return
+	}
+	if err = ctx.evalState().limiter.checkRange(rg.Total()); err != nil {
+		err = wrapError(ErrRuntime, e.pos, err)
+		return
+	}
+	ret = Value{Type: ValueRange, Value: rg}
+	return
+}
+
+func (e *rangeExpr) bound(expr Expr, ctx Context) (ret int64, err error) {
+	var val Value
+	if val, err = expr.Eval(ctx); err != nil {
+		return
+	}
+	inter, ok := val.RealValue().Value.(Inter)
+	if !ok {
+		err = errors.New("the begin, the end and the step of range must be ints")
+		return
+	}
+	return inter.Int()
+}
+
+type exitExpr struct{}
+
+func (e *exitExpr) Eval(ctx Context) (Value, error) {
+	Exit()
+	return NullValue(), nil
+}
+
+// emitExpr write the value as a document when the documents are collected, such as
+// Translator with Documents, the value of emit is the value emitted
+type emitExpr struct {
+	pos
+	val Expr
+}
+
+func (e *emitExpr) Eval(ctx Context) (ret Value, err error) {
+	if ret, err = e.val.Eval(ctx); err != nil {
+		return
+	}
+	ret = ret.RealValue()
+	if emit := ctx.evalState().emit; emit != nil {
+		err = wrapError(ErrRuntime, e.pos, emit(ret))
+	}
+	return
+}
+
+// ternaryExpr cond ? then : els
+type ternaryExpr struct {
+	cond, then, els Expr
+}
+
+func (e *ternaryExpr) Eval(ctx Context) (ret Value, err error) {
+	var cond Value
+	if cond, err = e.cond.Eval(ctx); err != nil {
+		return
+	}
+	if cond.RealValue().Bool() {
+		return e.then.Eval(ctx)
+	}
+	return e.els.Eval(ctx)
+}
+
+type ifBranch struct {
+	cond Expr
+	body *blockExpr
+}
+
+// ifExpr if cond { ... } else if cond { ... } else { ... }, the value is the value of the block
+// evaluated, null if none, a return in the block returns from the enclosing block too
+type ifExpr struct {
+	branches []ifBranch
+	els      *blockExpr
+}
+
+func (e *ifExpr) Eval(ctx Context) (ret Value, err error) {
+	body := e.els
+	for _, b := range e.branches {
+		var cond Value
+		if cond, err = b.cond.Eval(ctx); err != nil {
+			return
+		}
+		if cond.RealValue().Bool() {
+			body = b.body
+			break
+		}
+	}
+	if body == nil {
+		return NullValue(), nil
+	}
+	ctx.PushScope()
+	defer ctx.PopScope()
+	var returned bool
+	if ret, returned, err = body.evalReturned(ctx); returned {
+		ret = ReturnValue()
+	}
+	return
+}
+
+type returnExpr struct{}
+
+func (e *returnExpr) Eval(ctx Context) (Value, error) {
+	return ReturnValue(), nil
+}
+
+// blockExpr a list of stmts separated by semicolon, evaluated in a new scope,
+// the value of the block is the value of the last stmt before return
+type blockExpr struct {
+	stmts []Expr
+}
+
+func (e *blockExpr) Eval(ctx Context) (ret Value, err error) {
+	ctx.PushScope()
+	defer ctx.PopScope()
+	return e.eval(ctx)
+}
+
+func (e *blockExpr) eval(ctx Context) (ret Value, err error) {
+	ret, _, err = e.evalReturned(ctx)
+	return
+}
+
+// evalReturned evaluate the stmts like eval, and report whether a return stopped the block
+func (e *blockExpr) evalReturned(ctx Context) (ret Value, returned bool, err error) {
+	var val Value
+	for _, s := range e.stmts {
+		if err = ctx.evalState().limiter.step(); err != nil {
+			return
+		}
+		if val, err = s.Eval(ctx); err != nil {
+			return
+		}
+		if val.Type == ValueReturn {
+			returned = true
+			return
+		}
+		ret = val
+	}
+	return
+}
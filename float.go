@@ -35,7 +35,13 @@ func (i Float) Compare(val Value) (int, error) {
 		return 0, fmt.Errorf("can't compare float with [%s]", val.TypeName())
 	}
 	r, _ := val.Value.(Floater).Float()
-	return int(float64(i) - r), nil
+	switch {
+	case float64(i) < r:
+		return -1, nil
+	case float64(i) > r:
+		return 1, nil
+	}
+	return 0, nil
 }
 
 func (i Float) Add(val Value) (ret Value, err error) {
@@ -0,0 +1,26 @@
+package funcs
+
+import "djson"
+
+// Modules get the modules of the package keyed by the names used in djson, such as _http,
+// names filter the modules returned, all the modules are returned if no name given
+func Modules(names ...string) map[string]djson.Callable {
+	all := map[string]func() djson.Callable{
+		"_http": func() djson.Callable { return NewHttp() },
+		"_json": func() djson.Callable { return NewJson() },
+		"_log":  func() djson.Callable { return NewLog() },
+	}
+	ret := make(map[string]djson.Callable, len(all))
+	if len(names) == 0 {
+		for name, module := range all {
+			ret[name] = module()
+		}
+		return ret
+	}
+	for _, name := range names {
+		if module, ok := all[name]; ok {
+			ret[name] = module()
+		}
+	}
+	return ret
+}
@@ -0,0 +1,30 @@
+package funcs
+
+import (
+	"bytes"
+	"djson"
+	"strings"
+	"testing"
+)
+
+func TestModules(t *testing.T) {
+	if len(Modules()) != 3 {
+		t.Fatal("all modules should be returned")
+	}
+	modules := Modules("_json", "_none")
+	if _, ok := modules["_json"]; !ok || len(modules) != 1 {
+		t.Fatal("modules filter error")
+	}
+	trans := djson.NewTranslator(djson.NewJsonEncoder(), djson.Funcs(modules))
+	var out bytes.Buffer
+	if _, err := trans.Translate(strings.NewReader(`_json.decode(_json.encode({"a": 1})).a`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "1" {
+		t.Fatalf("module call error: %s", out.String())
+	}
+	_, err := trans.Translate(strings.NewReader(`_http.get({"url": "http://localhost"})`), &out)
+	if err == nil {
+		t.Fatal("module not registered should fail")
+	}
+}
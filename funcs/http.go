@@ -2,6 +2,7 @@ package funcs
 
 import (
 	"bytes"
+	"context"
 	"djson"
 	"fmt"
 	"io"
@@ -14,61 +15,52 @@ type httpc struct {
 
 func NewHttp() *httpc {
 	h := &httpc{CallableRegister: djson.NewCallableRegister("http")}
-	h.RegisterCall("get", h.getHttp)
-	h.RegisterCall("post", h.postHttp)
-	h.RegisterCall("put", h.putHttp)
-	h.RegisterCall("delete", h.deleteHttp)
-	h.RegisterCall("patch", h.patchHttp)
-	h.RegisterCall("head", h.headHttp)
+	h.RegisterContextCall("get", h.getHttp)
+	h.RegisterContextCall("post", h.postHttp)
+	h.RegisterContextCall("put", h.putHttp)
+	h.RegisterContextCall("delete", h.deleteHttp)
+	h.RegisterContextCall("patch", h.patchHttp)
+	h.RegisterContextCall("head", h.headHttp)
 	return h
 }
 
-func (h *httpc) getHttp(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
-	return h.doHttp(val, scanner, vars, http.MethodGet)
+func (h *httpc) getHttp(goctx context.Context, val djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	return h.doHttp(goctx, val, args, vars, http.MethodGet)
 }
 
-func (h *httpc) putHttp(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
-	return h.doHttp(val, scanner, vars, http.MethodPut)
+func (h *httpc) putHttp(goctx context.Context, val djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	return h.doHttp(goctx, val, args, vars, http.MethodPut)
 }
 
-func (h *httpc) postHttp(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
-	return h.doHttp(val, scanner, vars, http.MethodPost)
+func (h *httpc) postHttp(goctx context.Context, val djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	return h.doHttp(goctx, val, args, vars, http.MethodPost)
 }
 
-func (h *httpc) deleteHttp(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
-	return h.doHttp(val, scanner, vars, http.MethodDelete)
+func (h *httpc) deleteHttp(goctx context.Context, val djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	return h.doHttp(goctx, val, args, vars, http.MethodDelete)
 }
 
-func (h *httpc) patchHttp(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
-	return h.doHttp(val, scanner, vars, http.MethodPatch)
+func (h *httpc) patchHttp(goctx context.Context, val djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	return h.doHttp(goctx, val, args, vars, http.MethodPatch)
 }
 
-func (h *httpc) headHttp(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
-	return h.doHttp(val, scanner, vars, http.MethodHead)
+func (h *httpc) headHttp(goctx context.Context, val djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	return h.doHttp(goctx, val, args, vars, http.MethodHead)
 }
 
-func (h *httpc) doHttp(val djson.Value, scanner djson.TokenScanner, vars djson.Context, method string) (ret djson.Value, err error) {
-	stmt := djson.NewStmtExecutor(scanner, vars)
-	func() {
-		scanner.PushEnds(djson.TokenParenthesesClose)
-		defer scanner.PopEnds(djson.TokenParenthesesClose)
-		err = stmt.Execute()
-	}()
-	if stmt.Exited() {
-		djson.Exit()
-	}
-	if err != nil {
+func (h *httpc) doHttp(goctx context.Context, val djson.Value, args djson.Expr, vars djson.Context, method string) (ret djson.Value, err error) {
+	var pv djson.Value
+	if pv, err = args.Eval(vars); err != nil {
 		return
 	}
-	pv := stmt.Value().RealValue()
-	if pv.Type != djson.ValueObject {
-		err = fmt.Errorf("http only support a object as the params, current type is [%s]", val.TypeName())
+	if pv = pv.RealValue(); pv.Type != djson.ValueObject {
+		err = fmt.Errorf("http only support a object as the params, current type is [%s]", pv.TypeName())
 		return
 	}
 	p := pv.Value.(djson.Object)
 	var req *http.Request
 	var res *http.Response
-	if req, err = h.request(p, method); err != nil {
+	if req, err = h.request(goctx, p, method); err != nil {
 		return
 	}
 	cli := http.Client{}
@@ -103,12 +95,12 @@ func (h *httpc) response(res *http.Response) (ret djson.Value, err error) {
 	return
 }
 
-func (h *httpc) request(p djson.Object, method string) (req *http.Request, err error) {
+func (h *httpc) request(goctx context.Context, p djson.Object, method string) (req *http.Request, err error) {
 	var url string
 	if url, err = h.url(p); err != nil {
 		return
 	}
-	if req, err = http.NewRequest(method, url, h.body(p)); err != nil {
+	if req, err = http.NewRequestWithContext(goctx, method, url, h.body(p)); err != nil {
 		return
 	}
 	h.completeHeader(p, req)
@@ -1,12 +1,58 @@
 package funcs
 
-import "testing"
+import (
+	"context"
+	"djson"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestHttp_do(t *testing.T) {
-	// _http.get({
-	//   "url": "https://baidu.com",
-	//   "header": {
-	//      "Content-Type": "application/json"
-	//   }
-	// })
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"method": "` + r.Method + `"}`))
+	}))
+	defer srv.Close()
+	ctx := djson.NewContext(djson.Variable{Name: []byte("host"), Value: djson.StringValue([]byte(srv.URL)...)})
+	ctx.RegisterModule("_http", NewHttp())
+	ctx.RegisterModule("_json", NewJson())
+	prog, err := djson.Compile(strings.NewReader(`
+res = _http.post({"url": host + "/", "header": {"Content-Type": "application/json"}});
+[res.statusCode, _json.decode(res.body).method]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := prog.Eval(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := val.Value.(djson.Array)
+	if arr.Get(0).MustInt() != 200 || arr.Get(1).String() != "POST" {
+		t.Fatal("http response error")
+	}
+	prog, err = djson.Compile(strings.NewReader(`_http.get({"url": host + "/slow"})`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	goctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err = prog.EvalContext(goctx, ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("request should be canceled: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("request canceled too late")
+	}
 }
@@ -17,24 +17,24 @@ func NewJson() *jsonc {
 	return j
 }
 
-func (h *jsonc) decode(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
-	if byter, ok := val.Value.(djson.Byter); ok {
-		scanner := djson.NewTokenScanner(djson.NewLexer(bytes.NewBuffer(byter.Bytes()), 128))
-		stmt := djson.NewStmtExecutor(scanner, vars)
-		if err = stmt.Execute(); err != nil {
-			return
-		}
-		if stmt.Exited() {
-			djson.Exit()
-		}
-		ret = stmt.Value()
+// decode parse the strict json string to a value, nothing in the string is evaluated
+func (h *jsonc) decode(_ djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	var val djson.Value
+	if val, err = args.Eval(vars); err != nil {
 		return
 	}
-	err = fmt.Errorf("decode json only support a djson string")
+	if byter, ok := val.RealValue().Value.(djson.Byter); ok {
+		return djson.FromJSON(byter.Bytes())
+	}
+	err = fmt.Errorf("decode json only support a json string")
 	return
 }
 
-func (h *jsonc) encode(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
+func (h *jsonc) encode(_ djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	var val djson.Value
+	if val, err = args.Eval(vars); err != nil {
+		return
+	}
 	encoder := djson.NewJsonEncoder()
 	var buf bytes.Buffer
 	if _, err = encoder.Encode(val, &buf); err != nil {
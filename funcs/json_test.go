@@ -1,7 +1,34 @@
 package funcs
 
-import "testing"
+import (
+	"djson"
+	"strings"
+	"testing"
+)
 
 func TestJson_decode(t *testing.T) {
-	// _json.decode("{\"hello\": \"world\"}")
+	prog, err := djson.Compile(strings.NewReader(`
+body = _json.encode({"b": [1, 2.5, null], "a": true});
+_json.decode(body)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := djson.NewContext()
+	ctx.RegisterModule("_json", NewJson())
+	val, err := prog.Eval(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := val.Value.(djson.Object)
+	arr := obj.Get([]byte{'b'}).Value.(djson.Array)
+	if arr.Total() != 3 || arr.Get(0).MustInt() != 1 || !obj.Get([]byte{'a'}).Bool() {
+		t.Fatal("decode error")
+	}
+	// the djson code in the string isn't evaluated
+	ctx.Assign([]byte("body"), djson.StringValue([]byte("a = 1; exit")...))
+	prog, _ = djson.Compile(strings.NewReader(`_json.decode(body)`))
+	if _, err = prog.Eval(ctx); err == nil {
+		t.Fatal("djson code should fail to decode")
+	}
 }
@@ -1,29 +1,207 @@
 package funcs
 
-import "djson"
+import (
+	"bytes"
+	"djson"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel the level of a log entry
+type LogLevel int
+
+const (
+	LogDebug = LogLevel(iota)
+	LogInfo
+	LogError
+	LogFatal
+)
+
+// String get the name of the level
+func (l LogLevel) String() string {
+	return map[LogLevel]string{
+		LogDebug: "debug",
+		LogInfo:  "info",
+		LogError: "error",
+		LogFatal: "fatal",
+	}[l]
+}
+
+// LogField a key/value pair taken from the object argument of the log call
+type LogField struct {
+	Key   string
+	Value djson.Value
+}
+
+// LogEntry a line logged by _log
+type LogEntry struct {
+	Time   time.Time
+	Level  LogLevel
+	Msg    string
+	Fields []LogField
+}
+
+// LogWriter the sink where _log writes the entries to
+type LogWriter interface {
+	WriteLog(entry LogEntry) error
+}
+
+type textLogWriter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// TextLogWriter write the entries as the lines like `2006-01-02T15:04:05Z07:00 INFO msg key=value`
+func TextLogWriter(w io.Writer) LogWriter {
+	return &textLogWriter{w: w}
+}
+
+func (tw *textLogWriter) WriteLog(entry LogEntry) error {
+	var b bytes.Buffer
+	b.WriteString(entry.Time.Format(time.RFC3339))
+	b.WriteByte(' ')
+	b.WriteString(strings.ToUpper(entry.Level.String()))
+	b.WriteByte(' ')
+	b.WriteString(entry.Msg)
+	for _, f := range entry.Fields {
+		b.WriteByte(' ')
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		if err := writeLogValue(&b, f.Value); err != nil {
+			return err
+		}
+	}
+	b.WriteByte('\n')
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	_, err := tw.w.Write(b.Bytes())
+	return err
+}
+
+type jsonLogWriter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// JSONLogWriter write the entries as json lines, the fields are the members of the json object
+func JSONLogWriter(w io.Writer) LogWriter {
+	return &jsonLogWriter{w: w}
+}
+
+func (jw *jsonLogWriter) WriteLog(entry LogEntry) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `{"time":%s,"level":%s,"msg":%s`,
+		quoteLog(entry.Time.Format(time.RFC3339)), quoteLog(entry.Level.String()), quoteLog(entry.Msg))
+	for _, f := range entry.Fields {
+		fmt.Fprintf(&b, ",%s:", quoteLog(f.Key))
+		if err := writeLogValue(&b, f.Value); err != nil {
+			return err
+		}
+	}
+	b.WriteString("}\n")
+	jw.mu.Lock()
+	defer jw.mu.Unlock()
+	_, err := jw.w.Write(b.Bytes())
+	return err
+}
+
+func quoteLog(s string) string {
+	bs, _ := json.Marshal(s)
+	return string(bs)
+}
+
+func writeLogValue(w io.Writer, val djson.Value) error {
+	_, err := djson.NewJsonEncoder().Encode(val, w)
+	return err
+}
 
 type log_ struct {
 	*djson.CallableRegister
+	writer LogWriter
+	level  LogLevel
+}
+
+// LogOption the option of the _log module
+type LogOption func(l *log_)
+
+// LogTo set the writer of the log entries, the default writer writes text lines to the stderr
+func LogTo(w LogWriter) LogOption {
+	return func(l *log_) {
+		l.writer = w
+	}
 }
 
-func NewLog() *log_ {
-	l := &log_{CallableRegister: djson.NewCallableRegister("log")}
+// LogAbove log the entries whose level is not lower than level only, the default is LogInfo
+func LogAbove(level LogLevel) LogOption {
+	return func(l *log_) {
+		l.level = level
+	}
+}
+
+// NewLog new the _log module, such as
+//
+//	_log.info("user created", {"name": user.name});
+//	_log.fatal("bad config") # log and end the evaluation
+func NewLog(opts ...LogOption) *log_ {
+	l := &log_{CallableRegister: djson.NewCallableRegister("log"), writer: TextLogWriter(os.Stderr), level: LogInfo}
+	for _, apply := range opts {
+		apply(l)
+	}
 	l.RegisterCall("info", l.doInfo)
+	l.RegisterCall("print", l.doInfo)
 	l.RegisterCall("error", l.doError)
 	l.RegisterCall("debug", l.doDebug)
 	l.RegisterCall("fatal", l.doFatal)
 	return l
 }
 
-func (h *log_) doInfo(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
-	return
+func (h *log_) doInfo(val djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	return h.log(LogInfo, args, vars)
 }
-func (h *log_) doError(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
-	return
+
+func (h *log_) doError(val djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	return h.log(LogError, args, vars)
 }
-func (h *log_) doDebug(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
+
+func (h *log_) doDebug(val djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	return h.log(LogDebug, args, vars)
+}
+
+func (h *log_) doFatal(val djson.Value, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	if ret, err = h.log(LogFatal, args, vars); err != nil {
+		return
+	}
+	djson.Exit()
 	return
 }
-func (h *log_) doFatal(val djson.Value, scanner djson.TokenScanner, vars djson.Context) (ret djson.Value, err error) {
+
+// log evaluate the args, the objects are taken as the fields and the others are joined as the message
+func (h *log_) log(level LogLevel, args djson.Expr, vars djson.Context) (ret djson.Value, err error) {
+	if level < h.level {
+		return
+	}
+	entry := LogEntry{Time: time.Now(), Level: level}
+	msg := []string{}
+	for _, arg := range djson.Args(args) {
+		var val djson.Value
+		if val, err = arg.Eval(vars); err != nil {
+			return
+		}
+		if val = val.RealValue(); val.Type != djson.ValueObject {
+			msg = append(msg, val.String())
+			continue
+		}
+		val.Value.(djson.Object).Each(func(k []byte, v djson.Value) bool {
+			entry.Fields = append(entry.Fields, LogField{Key: string(k), Value: v})
+			return true
+		})
+	}
+	entry.Msg = strings.Join(msg, " ")
+	err = h.writer.WriteLog(entry)
 	return
 }
@@ -0,0 +1,74 @@
+package funcs
+
+import (
+	"bytes"
+	"djson"
+	"strings"
+	"testing"
+	"time"
+)
+
+type logRecorder struct {
+	entries []LogEntry
+}
+
+func (r *logRecorder) WriteLog(entry LogEntry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func TestLog_levels(t *testing.T) {
+	var r logRecorder
+	prog, err := djson.Compile(strings.NewReader(`
+_log.debug("hidden");
+_log.info("user", "created", {"name": "joe", "age": 3});
+_log.Print("hello world");
+_log.fatal("bad config");
+_log.error("unreachable");
+1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := djson.NewContext()
+	ctx.RegisterModule("_log", NewLog(LogTo(&r)))
+	val, err := prog.Eval(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.Type != djson.ValueNull {
+		t.Fatal("fatal should end the evaluation")
+	}
+	if len(r.entries) != 3 {
+		t.Fatalf("level filter error: %d", len(r.entries))
+	}
+	e := r.entries[0]
+	if e.Level != LogInfo || e.Msg != "user created" || len(e.Fields) != 2 || e.Fields[0].Key != "name" {
+		t.Fatalf("entry error: %v", e)
+	}
+	if r.entries[2].Level != LogFatal {
+		t.Fatal("fatal level error")
+	}
+}
+
+func TestLog_writers(t *testing.T) {
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := LogEntry{Time: now, Level: LogError, Msg: `say "hi"`, Fields: []LogField{
+		{Key: "name", Value: djson.StringValue([]byte("joe")...)},
+		{Key: "tags", Value: djson.ArrayValue(djson.NewArray(djson.IntValue(1), djson.IntValue(2)))},
+	}}
+	var b bytes.Buffer
+	if err := TextLogWriter(&b).WriteLog(entry); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "2021-01-02T03:04:05Z ERROR say \"hi\" name=\"joe\" tags=[1,2]\n" {
+		t.Fatalf("text line error: %s", b.String())
+	}
+	b.Reset()
+	if err := JSONLogWriter(&b).WriteLog(entry); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != `{"time":"2021-01-02T03:04:05Z","level":"error","msg":"say \"hi\"","name":"joe","tags":[1,2]}`+"\n" {
+		t.Fatalf("json line error: %s", b.String())
+	}
+}
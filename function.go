@@ -0,0 +1,109 @@
+package djson
+
+import (
+	"fmt"
+)
+
+// function a function defined in djson, such as (a, b) -> a + b, it is a closure
+// which captures the Context where it is defined
+type function struct {
+	params [][]byte
+	body   Expr
+	ctx    Context
+}
+
+var _ Callable = &function{}
+
+// call evaluate the args within vars, and the body within a new scope of the captured
+// Context, the params are declared in the scope
+func (f *function) call(_ string, _ Value, args Expr, vars Context) (ret Value, err error) {
+	items := Args(args)
+	if len(items) > len(f.params) {
+		err = fmt.Errorf("function accepts %d args, but %d given", len(f.params), len(items))
+		return
+	}
+	// the state of the evaluation comes from the caller
+	ctx := f.ctx.fork()
+	state := ctx.evalState()
+	*state = *vars.evalState()
+	state.depth++
+	if err = state.limiter.checkDepth(state.depth); err != nil {
+		return
+	}
+	if err = state.limiter.step(); err != nil {
+		return
+	}
+	ctx.PushScope()
+	for i, name := range f.params {
+		val := NullValue()
+		if i < len(items) {
+			if val, err = items[i].Eval(vars); err != nil {
+				return
+			}
+		}
+		ctx.Declare(name, val.RealValue())
+	}
+	if ret, err = f.body.Eval(ctx); err != nil {
+		return
+	}
+	ret = ret.RealValue()
+	return
+}
+
+type funcExpr struct {
+	pos
+	params [][]byte
+	body   Expr
+}
+
+func (e *funcExpr) Eval(ctx Context) (Value, error) {
+	return CallableValue(&function{params: e.params, body: e.body, ctx: ctx.fork()}), nil
+}
+
+// argsExpr the args separated by comma of a call, evaluated in order to the last one
+type argsExpr struct {
+	items []Expr
+}
+
+func (e *argsExpr) Eval(ctx Context) (ret Value, err error) {
+	for _, item := range e.items {
+		if ret, err = item.Eval(ctx); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Args split the args of a call separated by comma, so a Callback can evaluate them one by one
+func Args(args Expr) []Expr {
+	switch a := args.(type) {
+	case *argsExpr:
+		return a.items
+	case *blockExpr:
+		if len(a.stmts) == 0 {
+			return nil
+		}
+	}
+	return []Expr{args}
+}
+
+// paramsOf get the param names if left is a list of identifiers in parentheses
+func paramsOf(left Expr) (params [][]byte, ok bool) {
+	switch left.(type) {
+	case *argsExpr, *blockExpr:
+	default:
+		return
+	}
+	for _, item := range Args(left) {
+		block, isBlock := item.(*blockExpr)
+		if !isBlock || len(block.stmts) != 1 {
+			return
+		}
+		id, isIdent := block.stmts[0].(*identExpr)
+		if !isIdent {
+			return
+		}
+		params = append(params, id.name)
+	}
+	return params, true
+}
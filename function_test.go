@@ -0,0 +1,89 @@
+package djson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFunction_call(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`
+fmt_user = (u) -> {"name": u.name, "age": u.age};
+add = (a, b) -> a + b;
+now = () -> 42;
+id = (x) -> x;
+fmt_user({"name": "joe", "age": 3, "x": 1});
+[fmt_user({"name": "joe", "age": 3}), add(1, 2), now(), id(), [1 ... 3].map(add(v, i))]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := prog.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := val.Value.(Array)
+	user := arr.Get(0).Value.(Object)
+	if user.Total() != 2 || user.Get([]byte("name")).String() != "joe" {
+		t.Fatal("function returns object error")
+	}
+	if arr.Get(1).MustInt() != 3 || arr.Get(2).MustInt() != 42 {
+		t.Fatal("function call error")
+	}
+	if _, err := arr.Get(3).Int(); err == nil {
+		t.Fatal("missing arg should be null")
+	}
+	if arr.Get(4).Value.(Array).Get(1).MustInt() != 3 {
+		t.Fatal("function call in map error")
+	}
+}
+
+func TestFunction_closure(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`
+u = "outer";
+adder = (n) -> ((x) -> x + n);
+add2 = adder(2);
+greet = (u) -> "hi " + u;
+obj = {"f": (x) -> x * 2};
+[add2(3), greet("joe"), u, obj.f(4), [1, 2].map((i == 0) -> 5)]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := prog.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := val.Value.(Array)
+	if arr.Get(0).MustInt() != 5 {
+		t.Fatal("closure error")
+	}
+	if arr.Get(1).String() != "hi joe" || arr.Get(2).String() != "outer" {
+		t.Fatal("param scope error")
+	}
+	if arr.Get(3).MustInt() != 8 {
+		t.Fatal("member function error")
+	}
+	if arr.Get(4).Value.(Array).Get(0).MustInt() != 5 {
+		t.Fatal("-> as reduction error")
+	}
+}
+
+func TestFunction_recursive(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`
+fib = (n) -> (n; n < 2 => return; fib(n - 1) + fib(n - 2));
+fib(10)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := prog.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.MustInt() != 55 {
+		t.Fatalf("recursive error: %s", val)
+	}
+	if _, err = Compile(strings.NewReader(`f = (a, 1) -> a; f(1)`)); err != nil {
+		t.Fatal(err)
+	}
+}
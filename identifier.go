@@ -10,7 +10,7 @@ type Identifier interface {
 	Value() Value
 	Assign(val Value) error
 	SetParent(p Value)
-	Call(scanner TokenScanner, vars Context) (val Value, err error)
+	Call(args Expr, vars Context) (val Value, err error)
 }
 
 type identifier struct {
@@ -104,8 +104,12 @@ func (id identifier) root() (root interface{}, err error) {
 	return
 }
 
-func (id identifier) Call(scanner TokenScanner, ctx Context) (val Value, err error) {
+func (id identifier) Call(args Expr, ctx Context) (val Value, err error) {
 	name := id.name
+	// the function assigned to the variable or the member
+	if fn, ok := id.Value().RealValue().Value.(*function); ok {
+		return fn.call(string(name), id.p, args, ctx)
+	}
 	if id.p.Type == ValueNull {
 		err = fmt.Errorf("can't call function [%s] without caller", name)
 		return
@@ -116,5 +120,5 @@ func (id identifier) Call(scanner TokenScanner, ctx Context) (val Value, err err
 		err = fmt.Errorf("%s can't support call function", val.TypeName())
 		return
 	}
-	return call.call(string(name), val, scanner, ctx)
+	return call.call(string(name), val, args, ctx)
 }
@@ -0,0 +1,158 @@
+package djson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Loader load the sources of the imported files, the name is a slash separated path resolved
+// against the importing file, such as "ui/header.djson" imported by "ui/page.djson" as "header.djson"
+type Loader interface {
+	Load(name string) ([]byte, error)
+}
+
+type fileLoader struct{}
+
+// FileLoader a Loader reads the files from the file system, the relative names are relative to
+// the working directory
+func FileLoader() Loader {
+	return fileLoader{}
+}
+
+func (fileLoader) Load(name string) ([]byte, error) {
+	return os.ReadFile(filepath.FromSlash(name))
+}
+
+type fsLoader struct {
+	fsys fs.FS
+}
+
+// FSLoader a Loader reads the files from fsys, such as an embed.FS or os.DirFS(root), the names
+// out of fsys, such as "../a.djson", fail
+func FSLoader(fsys fs.FS) Loader {
+	return fsLoader{fsys: fsys}
+}
+
+func (l fsLoader) Load(name string) ([]byte, error) {
+	return fs.ReadFile(l.fsys, strings.TrimPrefix(name, "/"))
+}
+
+// MapLoader a Loader serves the sources in memory, the key is the name of the file
+type MapLoader map[string]string
+
+// Load implements the Loader
+func (l MapLoader) Load(name string) ([]byte, error) {
+	src, ok := l[name]
+	if !ok {
+		return nil, fmt.Errorf("open %s: %w", name, fs.ErrNotExist)
+	}
+	return []byte(src), nil
+}
+
+// WithLoader set the Loader of the imported files, import fails if no Loader set
+func WithLoader(l Loader) StmtOption {
+	return func(opt *option) {
+		opt.loader = l
+	}
+}
+
+// importExpr import "path.djson" as name, the variables of the imported file are assigned to name as an object
+type importExpr struct {
+	pos
+	file   string // the resolved name of the imported file
+	name   []byte
+	loader Loader
+	once   sync.Once
+	prog   *Program // the imported file, it's compiled once for all the evaluations
+	err    error    // the error of loading or compiling the imported file
+}
+
+func (e *importExpr) Eval(ctx Context) (ret Value, err error) {
+	if e.loader == nil {
+		err = newError(ErrRuntime, e.pos, fmt.Errorf("can't import [%s], no Loader is set", e.file))
+		return
+	}
+	imports := ctx.evalState().imports
+	for i, f := range imports {
+		if f == e.file {
+			chain := append(append([]string{}, imports[i:]...), e.file)
+			err = newError(ErrRuntime, e.pos, fmt.Errorf("import cycle: %s", strings.Join(chain, " -> ")))
+			return
+		}
+	}
+	e.once.Do(e.compile)
+	if err = e.err; err != nil {
+		return
+	}
+	var obj Object
+	if obj, err = e.prog.module(ctx); err != nil {
+		return
+	}
+	ret = ObjectValue(obj)
+	ctx.Assign(e.name, ret)
+	return
+}
+
+// compile load and compile the imported file
+func (e *importExpr) compile() {
+	src, err := e.loader.Load(e.file)
+	if err != nil {
+		e.err = newError(ErrRuntime, e.pos, err)
+		return
+	}
+	e.prog, e.err = Compile(bytes.NewReader(src), Filename(e.file), WithLoader(e.loader))
+}
+
+// parseImport parse the path and the name after import, such as import "ui.djson" as ui
+func parseImport(scanner TokenScanner, token *Token, opt *option) (ret Expr, err error) {
+	p := posOf(token)
+	next := func() (*Token, error) {
+		if _, err := scanner.Scan(); err != nil {
+			return nil, err
+		}
+		token := scanner.Token()
+		scanner.Forward()
+		return token, nil
+	}
+	var file, as, name *Token
+	if file, err = next(); err != nil {
+		return
+	}
+	if file.Type != TokenString {
+		err = expectError(file, TokenString)
+		return
+	}
+	e := &importExpr{pos: p, file: resolveImport(opt.file, string(file.Raw)), loader: opt.loader}
+	if as, err = next(); err != nil {
+		return
+	}
+	if as.Type != TokenIdentifier || string(as.Raw) != "as" {
+		err = newError(ErrParse, posOf(as), errors.New("expect [as] after the path of import"))
+		return
+	}
+	if name, err = next(); err != nil {
+		return
+	}
+	if name.Type != TokenIdentifier {
+		err = expectError(name, TokenIdentifier)
+		return
+	}
+	e.name = append([]byte{}, name.Raw...)
+	ret = e
+	return
+}
+
+// resolveImport resolve the imported name against the directory of the importing file
+func resolveImport(from, name string) string {
+	if pathpkg.IsAbs(name) || from == "" {
+		return pathpkg.Clean(filepath.ToSlash(name))
+	}
+	return pathpkg.Join(pathpkg.Dir(filepath.ToSlash(from)), name)
+}
@@ -0,0 +1,115 @@
+package djson
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+func TestImport_file(t *testing.T) {
+	translate := func(name string, opts ...StmtOption) string {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		ob := bytes.Buffer{}
+		opts = append(opts, Filename(name))
+		if _, err := NewTranslator(NewJsonEncoder("  "), StmtOpts(opts...)).Translate(f, &ob); err != nil {
+			t.Fatal(err)
+		}
+		return ob.String()
+	}
+	if translate("testdata/import.djson", WithLoader(FileLoader())) != translate("testdata/full.djson") {
+		t.Fatal("the imported templates should work as the inline ones")
+	}
+}
+
+func TestImport_loaders(t *testing.T) {
+	files := map[string]string{
+		"main.djson":         `import "lib/a.djson" as a; a.name + ":" + a.b.name + ":" + a.greet("x")`,
+		"lib/a.djson":        `name = "a"; import "b.djson" as b; greet = (who) -> prefix + who; prefix = "hi "`,
+		"lib/b.djson":        `name = "b"; x = main_only`,
+		"cycle/a.djson":      `import "b.djson" as b`,
+		"cycle/b.djson":      `import "./a.djson" as a`,
+		"broken/main.djson":  `import "bad.djson" as bad`,
+		"broken/bad.djson":   "x = 1;\ny = 1 +;",
+		"outside/main.djson": `import "../../secret.djson" as s`,
+	}
+	mfs := fstest.MapFS{}
+	for name, src := range files {
+		mfs[name] = &fstest.MapFile{Data: []byte(src)}
+	}
+	eval := func(l Loader, name string) (Value, error) {
+		prog, err := Compile(strings.NewReader(files[name]), Filename(name), WithLoader(l))
+		if err != nil {
+			return NullValue(), err
+		}
+		return prog.Eval(NewContext(Variable{Name: []byte("main_only"), Value: IntValue(1)}))
+	}
+	for _, l := range []Loader{MapLoader(files), FSLoader(mfs)} {
+		val, err := eval(l, "main.djson")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val.String() != "a:b:hi x" {
+			t.Fatalf("import error: %s", val.String())
+		}
+		if _, err = eval(l, "cycle/a.djson"); err == nil || !strings.Contains(err.Error(), "cycle/a.djson -> cycle/b.djson -> cycle/a.djson") {
+			t.Fatalf("cycle should be detected: %v", err)
+		}
+		var e *Error
+		if _, err = eval(l, "broken/main.djson"); !errors.As(err, &e) || e.File != "broken/bad.djson" || e.Row != 2 {
+			t.Fatalf("error should be located in the imported file: %v", err)
+		}
+	}
+	if _, err := eval(FSLoader(mfs), "outside/main.djson"); !errors.Is(err, fs.ErrInvalid) && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("import out of the fs should fail: %v", err)
+	}
+	if _, err := eval(nil, "main.djson"); err == nil {
+		t.Fatal("import without a Loader should fail")
+	}
+}
+
+type countLoader struct {
+	Loader
+	loads int32
+}
+
+func (l *countLoader) Load(name string) ([]byte, error) {
+	atomic.AddInt32(&l.loads, 1)
+	return l.Loader.Load(name)
+}
+
+func TestImport_once(t *testing.T) {
+	l := &countLoader{Loader: MapLoader{
+		"a.djson":    `import "b.djson" as b; x = b.y + 1`,
+		"b.djson":    `y = 1`,
+		"exit.djson": `x = 1; exit; y = 2`,
+	}}
+	prog, err := Compile(strings.NewReader(`
+xs = [0 ... 4].parallel(import "a.djson" as a; a.x + v);
+import "exit.djson" as e;
+[xs, e.x, e.y, 3]
+`), WithLoader(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		val, err := prog.Eval(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := jsonString(val); got != `[[2,3,4,5],1,null,3]` {
+			t.Fatalf("import error: %s", got)
+		}
+	}
+	if l.loads != 3 {
+		t.Fatalf("each file should be loaded once, got %d loads", l.loads)
+	}
+}
@@ -36,7 +36,13 @@ func (i Int) Compare(val Value) (int, error) {
 		return 0, fmt.Errorf("can't compare int with [%s]", val.TypeName())
 	}
 	r, _ := val.Value.(Inter).Int()
-	return int(int64(i) - r), nil
+	switch {
+	case int64(i) < r:
+		return -1, nil
+	case int64(i) > r:
+		return 1, nil
+	}
+	return 0, nil
 }
 
 func (i Int) Add(val Value) (ret Value, err error) {
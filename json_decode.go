@@ -0,0 +1,75 @@
+package djson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FromJSON decode the strict json to a Value without evaluating anything, the order of
+// the object keys is kept, and the integers are decoded to Int while the others to Float
+func FromJSON(data []byte) (ret Value, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if ret, err = decodeJSON(dec); err != nil {
+		return
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		ret = NullValue()
+		err = errors.New("invalid json: unexpected data after the top-level value")
+		return
+	}
+	err = nil
+	return
+}
+
+func decodeJSON(dec *json.Decoder) (ret Value, err error) {
+	var token json.Token
+	if token, err = dec.Token(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		err = fmt.Errorf("invalid json: %w", err)
+		return
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := NewObject()
+			for dec.More() {
+				if token, err = dec.Token(); err != nil {
+					return
+				}
+				var val Value
+				if val, err = decodeJSON(dec); err != nil {
+					return
+				}
+				obj.Set([]byte(token.(string)), val)
+			}
+			_, err = dec.Token()
+			ret = ObjectValue(obj)
+		case '[':
+			arr := NewArray()
+			for dec.More() {
+				var val Value
+				if val, err = decodeJSON(dec); err != nil {
+					return
+				}
+				arr.Append(val)
+			}
+			_, err = dec.Token()
+			ret = ArrayValue(arr)
+		}
+	default:
+		ret, err = FromGo(t)
+	}
+	return
+}
+
+// FromGo convert a go value to a Value, it's the same as ValueOf
+func FromGo(v interface{}) (Value, error) {
+	return ValueOf(v)
+}
@@ -0,0 +1,60 @@
+package djson
+
+import (
+	"testing"
+)
+
+func TestFromJSON(t *testing.T) {
+	val, err := FromJSON([]byte(`{"z": 1, "a": [1.5, "s", null, true, {}], "big": 12345678901234}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := val.Value.(Object)
+	var keys []string
+	obj.Each(func(k []byte, _ Value) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+	if len(keys) != 3 || keys[0] != "z" || keys[1] != "a" {
+		t.Fatal("keys order error")
+	}
+	if obj.Get([]byte("z")).Type != ValueInt || obj.Get([]byte("big")).MustInt() != 12345678901234 {
+		t.Fatal("int error")
+	}
+	arr := obj.Get([]byte("a")).Value.(Array)
+	if arr.Get(0).Type != ValueFloat || arr.Get(1).String() != "s" || arr.Get(2).Type != ValueNull ||
+		!arr.Get(3).Bool() || arr.Get(4).Type != ValueObject {
+		t.Fatal("array error")
+	}
+	for _, bad := range []string{`a = 1`, `{"a": 1`, `{"a": 1} 2`, `{a: 1}`, ``} {
+		if _, err := FromJSON([]byte(bad)); err == nil {
+			t.Fatalf("%s should be invalid", bad)
+		}
+	}
+}
+
+func TestFromGo(t *testing.T) {
+	val, err := FromGo(map[string]interface{}{
+		"b": []int{1, 2},
+		"a": uint8(3),
+		"c": map[string]*float64{"x": nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := val.Value.(Object)
+	var keys []string
+	obj.Each(func(k []byte, _ Value) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+	if len(keys) != 3 || keys[0] != "a" || keys[2] != "c" {
+		t.Fatal("keys should be sorted")
+	}
+	if obj.Get([]byte("b")).Value.(Array).Get(1).MustInt() != 2 || obj.Get([]byte("a")).MustInt() != 3 {
+		t.Fatal("convert error")
+	}
+	if _, err := FromGo(make(chan int)); err == nil {
+		t.Fatal("chan should fail")
+	}
+}
@@ -1,10 +1,23 @@
 package djson
 
 import (
+	"fmt"
 	"io"
 	"regexp"
+	"strconv"
 )
 
+// JsonError the error returned when a Value can't be expressed in json, such as a function
+// or a module
+type JsonError struct {
+	Type string // type name of the value
+}
+
+// Error implements the error
+func (e *JsonError) Error() string {
+	return fmt.Sprintf("can't encode [%s] to json", e.Type)
+}
+
 type jsonEncoder struct {
 	indent string
 }
@@ -21,6 +34,47 @@ func (jt jsonEncoder) Encode(val Value, w io.Writer) (int, error) {
 	return jt.encodeJSONIndent(val, w, []byte(jt.indent), []byte{})
 }
 
+// EncodeDocument implements the DocumentEncoder, the documents are written as NDJSON,
+// one document a line without the indent
+func (jt jsonEncoder) EncodeDocument(_ int, val Value, w io.Writer) (total int, err error) {
+	if total, err = jt.encodeJSONIndent(val, w, nil, nil); err != nil {
+		return
+	}
+	writes, err := w.Write([]byte{'\n'})
+	total += writes
+	return
+}
+
+// BeginArray implements the StreamEncoder
+func (jt jsonEncoder) BeginArray(w io.Writer) (int, error) {
+	return w.Write([]byte{'['})
+}
+
+// EncodeElement implements the StreamEncoder
+func (jt jsonEncoder) EncodeElement(i int, val Value, w io.Writer) (total int, err error) {
+	var b []byte
+	if i > 0 {
+		b = append(b, ',')
+	}
+	if len(jt.indent) > 0 {
+		b = append(append(b, '\n'), jt.indent...)
+	}
+	if total, err = w.Write(b); err != nil {
+		return
+	}
+	writes, err := jt.encodeJSONIndent(val, w, []byte(jt.indent), []byte(jt.indent))
+	total += writes
+	return
+}
+
+// EndArray implements the StreamEncoder
+func (jt jsonEncoder) EndArray(total int, w io.Writer) (int, error) {
+	if total > 0 && len(jt.indent) > 0 {
+		return w.Write([]byte{'\n', ']'})
+	}
+	return w.Write([]byte{']'})
+}
+
 func (jt jsonEncoder) encodeJSONIndent(val Value, w io.Writer, tab []byte, priv []byte) (totalWrites int, err error) {
 	var writes int
 	write := func(b []byte) bool {
@@ -31,7 +85,7 @@ func (jt jsonEncoder) encodeJSONIndent(val Value, w io.Writer, tab []byte, priv
 		totalWrites += writes
 		return true
 	}
-	val = val.RealValue()
+	val = encodable(val)
 	switch val.Type {
 	case ValueNull:
 		write([]byte{'n', 'u', 'l', 'l'})
@@ -39,9 +93,7 @@ func (jt jsonEncoder) encodeJSONIndent(val Value, w io.Writer, tab []byte, priv
 	case ValueInt, ValueBool:
 		write(val.Value.(Byter).Bytes())
 	case ValueString:
-		if write([]byte{'"'}) && write(val.Value.(Byter).Bytes()) && write([]byte{'"'}) {
-			return
-		}
+		write(quoteJSON(val.Value.(Byter).Bytes()))
 		return
 	case ValueFloat:
 		float := val.Value.(Byter).Bytes()
@@ -60,6 +112,8 @@ func (jt jsonEncoder) encodeJSONIndent(val Value, w io.Writer, tab []byte, priv
 			return
 		}
 		totalWrites += writes
+	default:
+		err = &JsonError{Type: val.TypeName()}
 	}
 	return
 }
@@ -82,7 +136,7 @@ func (jt jsonEncoder) encodeObjectJSON(obj *object, w io.Writer, tab []byte, pri
 		if len(indent) > 0 && !(write([]byte{'\n'}) && write(indent)) {
 			return
 		}
-		if !(write([]byte{'"'}) && write(p.key) && write([]byte{'"', ':'})) {
+		if !(write(quoteJSON(p.key)) && write([]byte{':'})) {
 			return
 		}
 		if writes, err = jt.encodeJSONIndent(p.val, w, tab, indent); err != nil {
@@ -93,7 +147,7 @@ func (jt jsonEncoder) encodeObjectJSON(obj *object, w io.Writer, tab []byte, pri
 			return
 		}
 	}
-	if len(obj.pairs) > 0 && write([]byte{'\n'}) && !write(priv) {
+	if len(obj.pairs) > 0 && len(tab) > 0 && write([]byte{'\n'}) && !write(priv) {
 		return
 	}
 	write([]byte{'}'})
@@ -114,7 +168,10 @@ func (jt jsonEncoder) encodeArrayJSON(arr *array, w io.Writer, tab []byte, priv
 		return
 	}
 	defer func() {
-		if len(arr.items) > 0 && !write([]byte{'\n'}) {
+		if err != nil {
+			return
+		}
+		if len(arr.items) > 0 && len(tab) > 0 && !write([]byte{'\n'}) {
 			return
 		}
 		_ = write(priv) && write([]byte{']'})
@@ -134,3 +191,36 @@ func (jt jsonEncoder) encodeArrayJSON(arr *array, w io.Writer, tab []byte, priv
 	}
 	return
 }
+
+// quoteJSON quote the bytes as a json string, the quote, the backslash and the control chars are escaped
+func quoteJSON(bs []byte) []byte {
+	ret := make([]byte, 0, len(bs)+2)
+	ret = append(ret, '"')
+	for _, b := range bs {
+		switch b {
+		case '"', '\\':
+			ret = append(ret, '\\', b)
+		case '\n':
+			ret = append(ret, '\\', 'n')
+		case '\r':
+			ret = append(ret, '\\', 'r')
+		case '\t':
+			ret = append(ret, '\\', 't')
+		case '\b':
+			ret = append(ret, '\\', 'b')
+		case '\f':
+			ret = append(ret, '\\', 'f')
+		default:
+			if b < 0x20 {
+				ret = append(ret, '\\', 'u', '0', '0')
+				if b < 0x10 {
+					ret = append(ret, '0')
+				}
+				ret = strconv.AppendUint(ret, uint64(b), 16)
+				continue
+			}
+			ret = append(ret, b)
+		}
+	}
+	return append(ret, '"')
+}
@@ -2,6 +2,8 @@ package djson
 
 import (
 	"bytes"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -66,3 +68,25 @@ func TestJsonEncoderObject(t *testing.T) {
 	)}, &buf)
 	t.Logf("%s\n", buf.String())
 }
+
+func TestJsonEncoderEscape(t *testing.T) {
+	var buf bytes.Buffer
+	obj := NewObject()
+	obj.Set([]byte("k\"ey"), StringValue([]byte("a\"b\\c\nd\te\x01é")...))
+	NewJsonEncoder().Encode(ObjectValue(obj), &buf)
+	if buf.String() != `{"k\"ey":"a\"b\\c\nd\te\u0001é"}` {
+		t.Fatalf("escape error: %s", buf.String())
+	}
+}
+
+func TestJsonEncoder_unsupported(t *testing.T) {
+	translator := NewTranslator(NewJsonEncoder(), Funcs(map[string]Callable{"_m": NewCallableRegister("m")}))
+	for i, src := range []string{`{"f": (x) -> x, "a": 1}`, `[1, _m]`} {
+		var out bytes.Buffer
+		_, err := translator.Translate(strings.NewReader(src), &out)
+		var je *JsonError
+		if !errors.As(err, &je) || je.Type != "callable" {
+			t.Fatalf("%d: json error expected, got %v", i, err)
+		}
+	}
+}
@@ -1,10 +1,17 @@
 package djson
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"sync"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 var (
@@ -23,9 +30,9 @@ var (
 )
 
 type candidate struct {
-	token        *Token
-	del          bool
-	dropLastChar bool
+	token *Token
+	del   bool
+	size  int // bytes of the token, the bytes taken after them are read again
 }
 
 type candidates struct {
@@ -109,9 +116,9 @@ func (tm matchers) match(b byte, s *stash, newc *candidates, matchers *matchers)
 		m := tm.matchers[i]
 		switch m.Match(b, s) {
 		case Matched:
-			newc.append(candidate{token: m.Token(), dropLastChar: true})
+			newc.append(candidate{token: m.Token(), size: s.Len()})
 		case Match:
-			newc.append(candidate{token: m.Token()})
+			newc.append(candidate{token: m.Token(), size: s.Len() + 1})
 		case Matching:
 			matching = true
 			matchers.append(m)
@@ -232,6 +239,23 @@ func (m *charsMatcher) Token() *Token {
 	return &m.token
 }
 
+type optionalDotMatcher struct {
+	charsMatcher
+}
+
+// OptionalDotMatcher match ?. unless a digit follows, so cond ?.5 : 1 is ? and .5
+func OptionalDotMatcher() TokenMatcher {
+	return &optionalDotMatcher{charsMatcher{chars: []byte{'?', '.'}, token: Token{Type: TokenOptionalDot}}}
+}
+
+// Match implement tokenMatcher.Match
+func (m *optionalDotMatcher) Match(b byte, stash Stash) MatchStatus {
+	if stash.Len() == len(m.chars) && isNumber(b) {
+		return NotMatch
+	}
+	return m.charsMatcher.Match(b, stash)
+}
+
 type identifierMatcher struct {
 	token Token
 }
@@ -244,9 +268,10 @@ func IdentifierMatcher() TokenMatcher {
 // Match implement tokenMatcher.Match
 func (m *identifierMatcher) Match(b byte, stash Stash) MatchStatus {
 	sl := stash.Len()
-	if sl == 0 && (isAlpha(b) || b == '_') || sl > 0 && isVarChar(b) {
+	// the multibyte chars are validated after the identifier matched, see checkIdentifier
+	if sl == 0 && (isAlpha(b) || b == '_' || b >= utf8.RuneSelf) || sl > 0 && (isVarChar(b) || b >= utf8.RuneSelf) {
 		return Matching
-	} else if sl > 0 && !isVarChar(b) {
+	} else if sl > 0 {
 		m.token.Raw = make([]byte, sl)
 		stash.CopyTo(m.token.Raw, 0)
 		return Matched
@@ -320,26 +345,136 @@ func StringMatcher() TokenMatcher {
 
 func (m *stringMatcher) Match(b byte, stash Stash) MatchStatus {
 	sl := stash.Len()
+	if sl == 0 {
+		m.slashed = false
+	}
 	if sl == 0 && b != '"' || b == 0 {
 		return NotMatch
 	}
+	if m.slashed {
+		m.slashed = false
+		return Matching
+	}
 	if b == '\\' {
 		m.slashed = true
 		return Matching
 	}
-	if sl > 0 && !m.slashed && b == '"' {
+	if sl > 0 && b == '"' {
 		m.token.Raw = make([]byte, sl-1)
 		stash.CopyTo(m.token.Raw, 1)
 		return Match
 	}
-	m.slashed = false
 	return Matching
 }
 
+// unescape decode the json escapes in the string, the surrogate pairs of \uXXXX are combined,
+// and the lone surrogates are replaced with U+FFFD. at is the offset of the invalid escape
+func unescape(raw []byte) (ret []byte, at int, err error) {
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return raw, 0, nil
+	}
+	ret = make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			ret = append(ret, raw[i])
+			continue
+		}
+		if i+1 >= len(raw) {
+			return nil, i, errors.New("unfinished escape in string")
+		}
+		i++
+		switch raw[i] {
+		case '"', '\\', '/':
+			ret = append(ret, raw[i])
+		case 'b':
+			ret = append(ret, '\b')
+		case 'f':
+			ret = append(ret, '\f')
+		case 'n':
+			ret = append(ret, '\n')
+		case 'r':
+			ret = append(ret, '\r')
+		case 't':
+			ret = append(ret, '\t')
+		case 'u':
+			r, ok := hex4(raw[i+1:])
+			if !ok {
+				return nil, i - 1, errors.New("invalid unicode escape in string, expect \\uXXXX")
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				r2 := unicode.ReplacementChar
+				if i+2 < len(raw) && raw[i+1] == '\\' && raw[i+2] == 'u' {
+					if lo, ok := hex4(raw[i+3:]); ok {
+						if r2 = utf16.DecodeRune(r, lo); r2 != unicode.ReplacementChar {
+							i += 6
+						}
+					}
+				}
+				r = r2
+			}
+			ret = append(ret, string(r)...)
+		default:
+			return nil, i - 1, fmt.Errorf("invalid escape [\\%c] in string", raw[i])
+		}
+	}
+	return ret, 0, nil
+}
+
+func hex4(bs []byte) (r rune, ok bool) {
+	if len(bs) < 4 {
+		return
+	}
+	v, err := strconv.ParseUint(string(bs[:4]), 16, 16)
+	if err != nil {
+		return
+	}
+	return rune(v), true
+}
+
 func (m *stringMatcher) Token() *Token {
 	return &m.token
 }
 
+type templateMatcher struct {
+	token   Token
+	slashed bool
+}
+
+// TemplateMatcher for matching the template string quoted by backticks, the raw is kept
+// as it is, the escapes and the embedded expressions are handled by the parser
+func TemplateMatcher() TokenMatcher {
+	return &templateMatcher{token: Token{Type: TokenTemplate}}
+}
+
+func (m *templateMatcher) Match(b byte, stash Stash) MatchStatus {
+	sl := stash.Len()
+	if sl == 0 {
+		m.slashed = false
+	}
+	if sl == 0 && b != '`' || b == 0 {
+		return NotMatch
+	}
+	if m.slashed {
+		m.slashed = false
+		return Matching
+	}
+	if b == '\\' {
+		m.slashed = true
+		return Matching
+	}
+	if sl > 0 && b == '`' {
+		m.token.Raw = make([]byte, sl-1)
+		stash.CopyTo(m.token.Raw, 1)
+		return Match
+	}
+	return Matching
+}
+
+func (m *templateMatcher) Token() *Token {
+	return &m.token
+}
+
 type eofMatcher struct {
 	token Token
 }
@@ -432,7 +567,9 @@ type lexer struct {
 	bs                     []byte
 	stash                  *stash
 	row, col               int
+	lastCol                int // col before the last line break
 	tokenAtCol, tokenAtRow int
+	unread                 []byte // the bytes put back, they are read before the buffer in the reverse order
 }
 
 // NewLexer new a Lexer
@@ -474,16 +611,25 @@ func NewLexer(source io.Reader, bufSize uint) *lexer {
 			CharsMatcher([]byte{'f', 'a', 'l', 's', 'e'}, TokenFalse),
 			CharsMatcher([]byte{'e', 'x', 'i', 't'}, TokenExit),
 			CharsMatcher([]byte{'r', 'e', 't', 'u', 'r', 'n'}, TokenReturn),
+			CharsMatcher([]byte{'e', 'm', 'i', 't'}, TokenEmit),
+			CharsMatcher([]byte{'i', 'm', 'p', 'o', 'r', 't'}, TokenImport),
+			CharsMatcher([]byte{'i', 'f'}, TokenIf),
+			CharsMatcher([]byte{'e', 'l', 's', 'e'}, TokenElse),
+			CharsMatcher([]byte{'?'}, TokenQuestion),
+			CharsMatcher([]byte{'?', '?'}, TokenCoalesce),
+			OptionalDotMatcher(),
 			CharsMatcher([]byte{'=', '>'}, TokenReduction),
-			CharsMatcher([]byte{'-', '>'}, TokenReduction),
+			CharsMatcher([]byte{'-', '>'}, TokenArrow),
 			CharsMatcher([]byte{'.', '.', '.'}, TokenRange),
+			CharsMatcher([]byte{'.', '.', '='}, TokenRangeInclusive),
 			IdentifierMatcher(),
 			WhitespaceMatcher(),
 			CommentMatcher(),
 			StringMatcher(),
+			TemplateMatcher(),
 			NumberMatcher(),
 			EOFMatcher(),
-		}, total: 39},
+		}, total: 48},
 	}
 }
 
@@ -514,15 +660,13 @@ nextToken:
 	cs := newCandidates()
 	g.stash.reset()
 	ms := g.matchers
+	taken := 0
 	for {
 		if ms.total == 0 {
 			break
 		}
-		if _, err := g.buf.Take(g.bs); err != nil {
-			if !errors.Is(err, io.EOF) {
-				return err
-			}
-			g.bs[0] = 0
+		if err := g.read(); err != nil {
+			return err
 		}
 		newc := candidatesPool.Get().(*candidates)
 		newc.reset()
@@ -534,20 +678,29 @@ nextToken:
 		g.stash.append(g.bs[0])
 		if matching || newc.len() > 0 {
 			g.forwardChar(g.bs[0])
+			taken++
 			cs.copyFrom(newc)
 			candidatesPool.Put(newc)
 			continue
 		}
-		g.buf.PutLast()
+		g.unread = append(g.unread, g.bs[0])
 		break
 	}
 	if cs.len() == 0 {
-		return fmt.Errorf("upexpected char: [%s] at %d, %d", g.bs, g.row, g.col)
+		err := newError(ErrLex, pos{row: g.row, col: g.col, span: 1}, fmt.Errorf("unexpected char [%s]", g.bs))
+		if g.row == g.tokenAtRow && g.col == g.tokenAtCol {
+			// skip the unexpected char, so the lexer can go on after the error
+			if e := g.read(); e == nil {
+				g.forwardChar(g.bs[0])
+			}
+		}
+		return err
 	}
 	cand := cs.slct()
-	if cand.dropLastChar {
-		g.backwardChar(g.bs[0])
-		g.buf.PutLast()
+	// the bytes taken after the token, such as the byte ends a number, or the . of ?.5
+	for i := taken - 1; i >= cand.size; i-- {
+		g.backwardChar(g.stash.buf[i])
+		g.unread = append(g.unread, g.stash.buf[i])
 	}
 	*token = *cand.token
 	if token.Skip() {
@@ -555,12 +708,50 @@ nextToken:
 	}
 	token.Col = g.tokenAtCol
 	token.Row = g.tokenAtRow
+	token.Span = 1
+	if g.row == g.tokenAtRow && g.col > g.tokenAtCol {
+		token.Span = g.col - g.tokenAtCol
+	}
+	if token.Type == TokenIdentifier {
+		raw, at, err := checkIdentifier(token.Raw)
+		if err != nil {
+			return newError(ErrLex, pos{row: g.tokenAtRow, col: g.tokenAtCol + at, span: 1}, err)
+		}
+		token.Raw = raw
+	}
+	if token.Type == TokenString {
+		raw, at, err := unescape(token.Raw)
+		if err != nil {
+			p := pos{row: g.tokenAtRow, col: g.tokenAtCol, span: token.Span}
+			if bytes.IndexByte(token.Raw[:at], '\n') < 0 {
+				p = pos{row: g.tokenAtRow, col: g.tokenAtCol + 1 + at, span: 2}
+			}
+			return newError(ErrLex, p, err)
+		}
+		token.Raw = raw
+	}
+	return nil
+}
+
+// read the next byte to bs, the bytes put back are read first, bs is 0 at the end of the source
+func (g *lexer) read() error {
+	if n := len(g.unread); n > 0 {
+		g.bs[0], g.unread = g.unread[n-1], g.unread[:n-1]
+		return nil
+	}
+	if _, err := g.buf.Take(g.bs); err != nil {
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+		g.bs[0] = 0
+	}
 	return nil
 }
 
 func (g *lexer) forwardChar(b byte) {
 	if b == '\n' {
 		g.row++
+		g.lastCol = g.col
 		g.col = 1
 	} else {
 		g.col++
@@ -570,12 +761,36 @@ func (g *lexer) forwardChar(b byte) {
 func (g *lexer) backwardChar(b byte) {
 	if b == '\n' {
 		g.row--
-		g.col = 0
+		g.col = g.lastCol
 	} else {
 		g.col--
 	}
 }
 
+// checkIdentifier check the unicode chars of the identifier are letters, digits or underscores,
+// the identifier is normalized to NFC, so the names typed in different forms are the same.
+// at is the offset of the invalid char
+func checkIdentifier(raw []byte) (ret []byte, at int, err error) {
+	ascii := true
+	for _, b := range raw {
+		if b >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return raw, 0, nil
+	}
+	for i := 0; i < len(raw); {
+		r, size := utf8.DecodeRune(raw[i:])
+		if r == utf8.RuneError || !(unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == '_') {
+			return nil, i, fmt.Errorf("invalid char [%s] in the identifier", raw[i:i+size])
+		}
+		i += size
+	}
+	return norm.NFC.Bytes(raw), 0, nil
+}
+
 func isWhitespace(b byte) bool {
 	return b == '\n' || b == '\t' || b == ' '
 }
@@ -54,7 +54,16 @@ func TestLexer_string(t *testing.T) {
 		{data: "\"124\"", val: []byte("124"), typ: TokenString},
 		{data: "\"hello world", shoulderr: true, typ: TokenString},
 		{data: "\"hello world\nhello\"", val: []byte("hello world\nhello"), typ: TokenString},
-		{data: "\"hello \\\"world\nhello\"", val: []byte("hello \\\"world\nhello"), typ: TokenString},
+		{data: "\"hello \\\"world\nhello\"", val: []byte("hello \"world\nhello"), typ: TokenString},
+		{data: `"a\\" + "b"`, val: []byte(`a\`), typ: TokenString},
+		{data: `"\n\t\r\b\f\/"`, val: []byte("\n\t\r\b\f/"), typ: TokenString},
+		{data: `"\u00e9\u4e2d\ud83d\ude00"`, val: []byte("é中😀"), typ: TokenString},
+		{data: `"\ud83d!"`, val: []byte("\ufffd!"), typ: TokenString},
+		{data: `"\x"`, shoulderr: true, typ: TokenString},
+		{data: `"\u12"`, shoulderr: true, typ: TokenString},
+		{data: "`a-${v.name}`", val: []byte("a-${v.name}"), typ: TokenTemplate},
+		{data: "`a\\`b\\n`", val: []byte("a\\`b\\n"), typ: TokenTemplate},
+		{data: "`a", shoulderr: true, typ: TokenTemplate},
 	}
 	for i, item := range data {
 		g := NewLexer(bytes.NewBuffer([]byte(item.data)), 32)
@@ -65,6 +74,9 @@ func TestLexer_string(t *testing.T) {
 			}
 			t.Fatal(err)
 		}
+		if item.shoulderr {
+			t.Fatalf("error should occur at %d", i)
+		}
 		if token.Type != item.typ || !bytes.Equal(item.val, token.Raw) {
 			t.Fatalf("error occurred at %d", i)
 		}
@@ -72,8 +84,41 @@ func TestLexer_string(t *testing.T) {
 	}
 }
 
+func TestLexer_unicodeIdentifier(t *testing.T) {
+	data := []struct {
+		data      string
+		val       []byte
+		shoulderr bool
+	}{
+		{data: "标签 = 1", val: []byte("标签")},
+		{data: "user_名2", val: []byte("user_名2")},
+		{data: "名.name", val: []byte("名")},
+		// the NFD form is normalized to NFC
+		{data: "cafe\u0301 = 1", val: []byte("caf\u00e9")},
+		{data: "a\u00b7b", shoulderr: true},
+		{data: "a\u00a0b", shoulderr: true},
+	}
+	for i, item := range data {
+		g := NewLexer(strings.NewReader(item.data), 32)
+		var token Token
+		err := g.NextToken(&token)
+		if item.shoulderr {
+			if err == nil {
+				t.Fatalf("error should occur at %d", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if token.Type != TokenIdentifier || !bytes.Equal(item.val, token.Raw) {
+			t.Fatalf("error occurred at %d: %#v", i, token)
+		}
+	}
+}
+
 func TestLexer_range(t *testing.T) {
-	data := "[1 ... 10]"
+	data := "[1 ... 10] [1 ..= 10 : 2]"
 	g := NewLexer(strings.NewReader(data), 16)
 	tokens := []*Token{
 		{Type: TokenBracketsOpen},
@@ -81,6 +126,13 @@ func TestLexer_range(t *testing.T) {
 		{Type: TokenRange},
 		{Type: TokenNumber, Raw: []byte{'1', '0'}},
 		{Type: TokenBracketsClose},
+		{Type: TokenBracketsOpen},
+		{Type: TokenNumber, Raw: []byte{'1'}},
+		{Type: TokenRangeInclusive},
+		{Type: TokenNumber, Raw: []byte{'1', '0'}},
+		{Type: TokenColon},
+		{Type: TokenNumber, Raw: []byte{'2'}},
+		{Type: TokenBracketsClose},
 	}
 	var token Token
 	for i, to := range tokens {
@@ -227,3 +279,27 @@ func BenchmarkLexer_NextToken(n *testing.B) {
 		}
 	}
 }
+
+func TestLexer_optionalDot(t *testing.T) {
+	data := "a?.b ?.5 :\n1"
+	g := NewLexer(strings.NewReader(data), 4)
+	tokens := []*Token{
+		{Type: TokenIdentifier, Raw: []byte{'a'}, Col: 1},
+		{Type: TokenOptionalDot, Col: 2},
+		{Type: TokenIdentifier, Raw: []byte{'b'}, Col: 4},
+		{Type: TokenQuestion, Col: 6},
+		{Type: TokenDot, Col: 7},
+		{Type: TokenNumber, Raw: []byte{'5'}, Col: 8},
+		{Type: TokenColon, Col: 10},
+		{Type: TokenNumber, Raw: []byte{'1'}, Col: 1},
+	}
+	var token Token
+	for i, to := range tokens {
+		if err := g.NextToken(&token); err != nil {
+			t.Fatal(err)
+		}
+		if to.Type != token.Type || to.Col != token.Col || to.Raw != nil && !bytes.Equal(to.Raw, token.Raw) {
+			t.Fatalf("token error at %d: %#v", i, token)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package djson
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Limits the resource limits of an evaluation, the zero value of a field means no limit
+type Limits struct {
+	Steps      int64         // stmts, calls and iterations of the callbacks evaluated
+	RangeLen   int           // length of a range
+	OutputSize int           // bytes the Translator writes, and of a string built, such as by repeat
+	Goroutines int           // goroutines the parallel calls run at the same time, the items wait for a free one
+	Depth      int           // depth of the nested function calls, DefaultDepth if not set
+	Timeout    time.Duration // wall-clock time of an evaluation
+}
+
+// LimitKind the kind of the limit exceeded
+type LimitKind int
+
+const (
+	LimitSteps = LimitKind(iota)
+	LimitRangeLen
+	LimitOutputSize
+	LimitGoroutines
+	LimitDepth
+	LimitTimeout
+)
+
+// String get the name of the limit
+func (k LimitKind) String() string {
+	return map[LimitKind]string{
+		LimitSteps:      "steps",
+		LimitRangeLen:   "range length",
+		LimitOutputSize: "output size",
+		LimitGoroutines: "goroutines",
+		LimitDepth:      "depth",
+		LimitTimeout:    "timeout",
+	}[k]
+}
+
+// LimitError the error returned when a limit exceeded, use errors.As to get it from the
+// error of the evaluation
+type LimitError struct {
+	Kind LimitKind
+	Max  int64 // the limit, in nanoseconds for LimitTimeout
+	Err  error // the cause, such as context.DeadlineExceeded
+}
+
+// Error implements the error
+func (e *LimitError) Error() string {
+	if e.Kind == LimitTimeout {
+		return fmt.Sprintf("evaluation stopped: %v", e.Err)
+	}
+	return fmt.Sprintf("limit of %s exceeded, max is %d", e.Kind, e.Max)
+}
+
+// Unwrap get the cause of the LimitError
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// WithLimits evaluate the Program compiled or the stmts executed within the Limits
+func WithLimits(l Limits) StmtOption {
+	return func(opt *option) {
+		opt.limits = &l
+	}
+}
+
+// limiter counts the resources used by an evaluation and watches the cancellation of the
+// context.Context, it's shared by the Contexts copied from the Context of the evaluation,
+// so it is safe for the concurrent use
+type limiter struct {
+	Limits
+	steps      int64
+	goroutines int64
+	done       <-chan struct{}
+	parent     context.Context
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+func newLimiter(parent context.Context, l Limits) *limiter {
+	lt := &limiter{Limits: l, parent: parent, ctx: parent}
+	if l.Timeout > 0 {
+		lt.ctx, lt.cancel = context.WithTimeout(parent, l.Timeout)
+	}
+	lt.done = lt.ctx.Done()
+	return lt
+}
+
+func (l *limiter) stop() {
+	if l != nil && l.cancel != nil {
+		l.cancel()
+	}
+}
+
+// step count a step, and check whether the evaluation should stop
+func (l *limiter) step() error {
+	if l == nil {
+		return nil
+	}
+	if l.Steps > 0 && atomic.AddInt64(&l.steps, 1) > l.Steps {
+		return &LimitError{Kind: LimitSteps, Max: l.Steps}
+	}
+	select {
+	case <-l.done:
+		// canceled by the caller, or the timeout of the Limits
+		if err := l.parent.Err(); err != nil {
+			return err
+		}
+		return &LimitError{Kind: LimitTimeout, Max: int64(l.Timeout), Err: l.ctx.Err()}
+	default:
+		return nil
+	}
+}
+
+func (l *limiter) checkRange(length int) error {
+	if l == nil || l.RangeLen <= 0 || length <= l.RangeLen {
+		return nil
+	}
+	return &LimitError{Kind: LimitRangeLen, Max: int64(l.RangeLen)}
+}
+
+// checkSize check the bytes of a value built, such as a string repeated
+func (l *limiter) checkSize(size int) error {
+	if l == nil || l.OutputSize <= 0 || size <= l.OutputSize {
+		return nil
+	}
+	return &LimitError{Kind: LimitOutputSize, Max: int64(l.OutputSize)}
+}
+
+// DefaultDepth the depth of the nested function calls if Limits.Depth isn't set, the
+// deeper calls may overflow the stack, which can't be recovered
+const DefaultDepth = 10000
+
+// checkDepth check the depth with DefaultDepth even if there's no limiter
+func (l *limiter) checkDepth(depth int) error {
+	max := DefaultDepth
+	if l != nil && l.Depth > 0 {
+		max = l.Depth
+	}
+	if depth <= max {
+		return nil
+	}
+	return &LimitError{Kind: LimitDepth, Max: int64(max)}
+}
+
+// acquire n goroutines at most, they must be released after the goroutines ended, the number
+// acquired is returned, it's 0 if none is available
+func (l *limiter) acquire(n int) int {
+	if l == nil || l.Goroutines <= 0 {
+		return n
+	}
+	for {
+		used := atomic.LoadInt64(&l.goroutines)
+		free := int64(l.Goroutines) - used
+		if free <= 0 {
+			return 0
+		}
+		if free > int64(n) {
+			free = int64(n)
+		}
+		if atomic.CompareAndSwapInt64(&l.goroutines, used, used+free) {
+			return int(free)
+		}
+	}
+}
+
+func (l *limiter) release(n int) {
+	if l == nil || l.Goroutines <= 0 {
+		return
+	}
+	atomic.AddInt64(&l.goroutines, -int64(n))
+}
+
+// evalState the state of an evaluation carried by the Context
+type evalState struct {
+	limiter *limiter
+	depth   int               // depth of the function calls
+	goctx   context.Context   // the context.Context of the evaluation, nil for context.Background
+	emit    func(Value) error // write the value of emit as a document, nil if the documents aren't collected
+	imports []string          // the files being evaluated, the outermost first, to detect the import cycles
+}
+
+func (s *evalState) context() context.Context {
+	if s.goctx == nil {
+		return context.Background()
+	}
+	return s.goctx
+}
+
+// limitedWriter fails with a LimitError when more than max bytes written
+type limitedWriter struct {
+	w       io.Writer
+	max     int
+	written int
+}
+
+func (lw *limitedWriter) Write(p []byte) (n int, err error) {
+	if lw.written+len(p) > lw.max {
+		return 0, &LimitError{Kind: LimitOutputSize, Max: int64(lw.max)}
+	}
+	n, err = lw.w.Write(p)
+	lw.written += n
+	return
+}
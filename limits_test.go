@@ -0,0 +1,115 @@
+package djson
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	data := []struct {
+		src    string
+		limits Limits
+		kind   LimitKind
+	}{
+		{src: `[0 ... 1000].map(v * 2)`, limits: Limits{Steps: 100}, kind: LimitSteps},
+		{src: `a = 1; [0 ... 1000000]`, limits: Limits{RangeLen: 1000}, kind: LimitRangeLen},
+		{src: `[0 ... 9223372036854775807 : 2].map(v)`, limits: Limits{RangeLen: 1000}, kind: LimitRangeLen},
+		{src: `[0 ... 2].parallel([0 ... 2].parallel(v))`, limits: Limits{Goroutines: 1}, kind: LimitGoroutines},
+		{src: `"ab".repeat(1000)`, limits: Limits{OutputSize: 100}, kind: LimitOutputSize},
+		{src: `"ab".padLeft(1000000000, "-")`, limits: Limits{OutputSize: 100}, kind: LimitOutputSize},
+		{src: `f = (n) -> f(n + 1); f(0)`, limits: Limits{Depth: 100}, kind: LimitDepth},
+		{src: `[0 ... 100000000].each(v)`, limits: Limits{Timeout: 10 * time.Millisecond}, kind: LimitTimeout},
+	}
+	for i, item := range data {
+		prog, err := Compile(strings.NewReader(item.src), WithLimits(item.limits))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = prog.Eval(nil)
+		var le *LimitError
+		if !errors.As(err, &le) || le.Kind != item.kind {
+			t.Fatalf("%d: limit error expected, got %v", i, err)
+		}
+	}
+	prog, err := Compile(strings.NewReader(`[0 ... 10].parallel(v * 2)`), WithLimits(Limits{Steps: 100, Goroutines: 10}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = prog.Eval(nil); err != nil {
+			t.Fatalf("the limits should be reset for each evaluation: %v", err)
+		}
+	}
+}
+
+func TestLimits_goroutines(t *testing.T) {
+	var running, most int64
+	probe := NewCallableRegister("probe")
+	probe.RegisterCall("run", func(_ Value, args Expr, ctx Context) (Value, error) {
+		n := atomic.AddInt64(&running, 1)
+		defer atomic.AddInt64(&running, -1)
+		for {
+			m := atomic.LoadInt64(&most)
+			if n <= m || atomic.CompareAndSwapInt64(&most, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return args.Eval(ctx)
+	})
+	prog, err := Compile(strings.NewReader(`[0 ... 100].parallel(_probe.run(v * 2))`), WithLimits(Limits{Goroutines: 4}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext()
+	ctx.RegisterModule("_probe", probe)
+	val, err := prog.Eval(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := val.Value.(Array)
+	if arr.Total() != 100 || arr.Get(99).MustInt() != 198 {
+		t.Fatal("parallel error")
+	}
+	if most > 4 {
+		t.Fatalf("%d items ran at the same time, the limit is 4", most)
+	}
+}
+
+func TestLimits_output(t *testing.T) {
+	translator := NewTranslator(NewJsonEncoder(), Limited(Limits{OutputSize: 16}))
+	var out bytes.Buffer
+	if _, err := translator.Translate(strings.NewReader(`[0, 1, 2]`), &out); err != nil {
+		t.Fatal(err)
+	}
+	_, err := translator.Translate(strings.NewReader(`[0 ... 100].map(v)`), &out)
+	var le *LimitError
+	if !errors.As(err, &le) || le.Kind != LimitOutputSize {
+		t.Fatalf("output size limit error expected, got %v", err)
+	}
+}
+
+func TestLimits_defaultDepth(t *testing.T) {
+	for i, src := range []string{`f = (n) -> f(n); f(1)`, `f = (n) -> f(n + 1); [0 ... 2].parallel(f(v))`} {
+		prog, err := Compile(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = prog.Eval(nil)
+		var le *LimitError
+		if !errors.As(err, &le) || le.Kind != LimitDepth || le.Max != DefaultDepth {
+			t.Fatalf("%d: depth limit error expected, got %v", i, err)
+		}
+	}
+	prog, err := Compile(strings.NewReader(`f = (n) -> n == 0 ? 0 : 1 + f(n - 1); f(5000)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, err := prog.Eval(nil); err != nil || val.MustInt() != 5000 {
+		t.Fatalf("the calls within the default depth should work: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"djson"
+	"djson/funcs"
 	"flag"
 	"fmt"
 	"io"
@@ -15,14 +16,35 @@ var (
 	outputFormat string
 	indent       string
 	bufSize      uint
+	modules      = map[string]*bool{}
+	logFormat    string
+	logLevel     string
+	stream       bool
+	documents    bool
+	vars         varsFlag
+	varsFile     string
+	envPrefix    string
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(check(os.Args[2:]))
+	}
 	flag.StringVar(&file, "f", "", "input pathfile")
 	flag.StringVar(&input, "i", "", "input bytes")
-	flag.StringVar(&outputFormat, "o", "json", "output format, current support: json, default is json")
+	flag.StringVar(&outputFormat, "o", "json", "output format, current support: json, yaml, toml, default is json")
 	flag.UintVar(&bufSize, "b", 512, "buffer size, default is 512")
 	flag.StringVar(&indent, "indent", "  ", "buffer size, default is \"  \"")
+	for _, name := range []string{"http", "json", "log"} {
+		modules[name] = flag.Bool(name, true, fmt.Sprintf("enable the module _%s, default is true", name))
+	}
+	flag.StringVar(&logFormat, "log-format", "text", "format of the _log lines, current support: text, json, default is text")
+	flag.StringVar(&logLevel, "log-level", "info", "the lowest level _log writes, one of debug, info, error, fatal, default is info")
+	flag.BoolVar(&stream, "stream", false, "write the elements of a top-level array mapped from a range one by one, json and yaml only")
+	flag.BoolVar(&documents, "documents", false, "write each top-level expression and emitted value as a document, as NDJSON for json and separated by --- for yaml")
+	flag.Var(&vars, "var", "declare a variable as name=value, the value is a json literal or a string, can be repeated")
+	flag.StringVar(&varsFile, "vars-file", "", "declare the variables in the json object of the file")
+	flag.StringVar(&envPrefix, "env", "", "declare the environment variables with the prefix as strings, the prefix is trimmed from the names")
 	flag.Parse()
 	var r io.Reader
 	var f *os.File
@@ -50,14 +72,175 @@ func main() {
 	switch outputFormat {
 	case "json":
 		encoder = djson.NewJsonEncoder(indent)
+	case "yaml":
+		encoder = djson.NewYamlEncoder(djson.YamlIndent(len(indent)))
+	case "toml":
+		encoder = djson.NewTomlEncoder()
 	default:
 		fmt.Printf("decoder [%s] not support", outputFormat)
 		os.Exit(1)
 	}
-	trans := djson.NewTranslator(encoder, djson.BuffSize(bufSize))
+	enabled := []string{}
+	for name, on := range modules {
+		if *on {
+			enabled = append(enabled, "_"+name)
+		}
+	}
+	mods := map[string]djson.Callable{}
+	if len(enabled) > 0 {
+		mods = funcs.Modules(enabled...)
+	}
+	if _, ok := mods["_log"]; ok {
+		mods["_log"] = newLog()
+	}
+	streaming, multiple := djson.StmtOpts(), djson.StmtOpts()
+	if stream {
+		streaming = djson.Streaming()
+	}
+	if documents {
+		multiple = djson.Documents()
+	}
+	ctx, err := hostContext()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	trans := djson.NewTranslator(encoder,
+		djson.Ctx(ctx),
+		djson.BuffSize(bufSize),
+		djson.StmtOpts(djson.Filename(file), djson.WithLoader(djson.FileLoader())),
+		djson.Funcs(mods),
+		streaming,
+		multiple)
 	if _, err := trans.Translate(r, os.Stdout); err != nil {
-		fmt.Printf("translate failed: %s", err.Error())
+		fmt.Fprintf(os.Stderr, "translate failed: %s\n", err.Error())
 		os.Exit(1)
 	}
 	os.Exit(0)
 }
+
+// check report the problems of the djson files without evaluating them, usage:
+//
+//	djson check [-i input] [file ...]
+func check(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.StringVar(&input, "i", "", "input bytes")
+	fs.Parse(args)
+	total := 0
+	if input != "" {
+		total += report(strings.NewReader(input), "")
+	}
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "can't open file: %s: %s\n", name, err.Error())
+			total++
+			continue
+		}
+		total += report(f, name)
+		f.Close()
+	}
+	if input == "" && fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "plz specific files or a byte string with -i\n")
+		return 1
+	}
+	if total > 0 {
+		return 1
+	}
+	return 0
+}
+
+func report(r io.Reader, name string) int {
+	ds := djson.Check(r, djson.Filename(name))
+	for _, d := range ds {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	return len(ds)
+}
+
+// varsFlag the name=value pairs of the repeated -var
+type varsFlag []string
+
+func (vf *varsFlag) String() string {
+	return strings.Join(*vf, ",")
+}
+
+func (vf *varsFlag) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("expect name=value, got [%s]", v)
+	}
+	*vf = append(*vf, v)
+	return nil
+}
+
+// hostContext declare the variables of -env, -vars-file and -var in order, the latter ones
+// override the former ones with the same names
+func hostContext() (djson.Context, error) {
+	var names []string
+	values := map[string]djson.Value{}
+	declare := func(name string, val djson.Value) {
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		values[name] = val
+	}
+	if envPrefix != "" {
+		for _, kv := range os.Environ() {
+			if pair := strings.SplitN(kv, "=", 2); strings.HasPrefix(pair[0], envPrefix) && pair[0] != envPrefix {
+				declare(strings.TrimPrefix(pair[0], envPrefix), djson.StringValue([]byte(pair[1])...))
+			}
+		}
+	}
+	if varsFile != "" {
+		data, err := os.ReadFile(varsFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read vars file: %s: %w", varsFile, err)
+		}
+		val, err := djson.FromJSON(data)
+		if err != nil || val.Type != djson.ValueObject {
+			return nil, fmt.Errorf("vars file %s should be a json object: %v", varsFile, err)
+		}
+		val.Value.(djson.Object).Each(func(k []byte, v djson.Value) bool {
+			declare(string(k), v)
+			return true
+		})
+	}
+	for _, kv := range vars {
+		pair := strings.SplitN(kv, "=", 2)
+		val, err := djson.FromJSON([]byte(pair[1]))
+		if err != nil {
+			val = djson.StringValue([]byte(pair[1])...)
+		}
+		declare(pair[0], val)
+	}
+	vs := make([]djson.Variable, len(names))
+	for i, name := range names {
+		vs[i] = djson.Variable{Name: []byte(name), Value: values[name]}
+	}
+	return djson.NewContext(vs...), nil
+}
+
+func newLog() djson.Callable {
+	var w funcs.LogWriter
+	switch logFormat {
+	case "text":
+		w = funcs.TextLogWriter(os.Stderr)
+	case "json":
+		w = funcs.JSONLogWriter(os.Stderr)
+	default:
+		fmt.Fprintf(os.Stderr, "log format [%s] not support\n", logFormat)
+		os.Exit(1)
+	}
+	levels := map[string]funcs.LogLevel{
+		"debug": funcs.LogDebug,
+		"info":  funcs.LogInfo,
+		"error": funcs.LogError,
+		"fatal": funcs.LogFatal,
+	}
+	level, ok := levels[logLevel]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "log level [%s] not support\n", logLevel)
+		os.Exit(1)
+	}
+	return funcs.NewLog(funcs.LogTo(w), funcs.LogAbove(level))
+}
@@ -43,16 +43,27 @@ var _ Object = &object{}
 func NewObject(pairs ...*pair) *object {
 	obj := &object{pairs: pairs, CallableRegister: NewCallableRegister("object")}
 	obj.RegisterCall("map", setObject)
+	obj.RegisterCall("set", setObject)
 	obj.RegisterCall("trans", transObject)
 	obj.RegisterCall("replace", replaceObject)
 	obj.RegisterCall("del", delObject)
 	obj.RegisterCall("filter", getObject)
+	obj.RegisterCall("keys", keysObject)
+	obj.RegisterCall("values", valuesObject)
+	obj.RegisterCall("entries", entriesObject)
+	obj.RegisterCall("pick", pickObject("pick", true))
+	obj.RegisterCall("omit", pickObject("omit", false))
+	obj.RegisterCall("mapKeys", mapKeysObject)
+	obj.RegisterCall("merge", mergeObject)
+	obj.RegisterCall("has", hasObject)
+	obj.RegisterCall("len", lenObject)
+	obj.RegisterCall("get", getPathObject)
 	return obj
 }
 
-func setObject(val Value, nexter TokenScanner, vars Context) (ret Value, err error) {
+func setObject(val Value, args Expr, vars Context) (ret Value, err error) {
 	r := val.Value.(Object).Copy()
-	err = eachObjectItemForSet(val, nexter, vars, func(k []byte, val Value) error {
+	err = eachObjectItemForSet(val, args, vars, func(k []byte, val Value) error {
 		r.Set(k, val)
 		return nil
 	})
@@ -60,9 +71,9 @@ func setObject(val Value, nexter TokenScanner, vars Context) (ret Value, err err
 	return
 }
 
-func replaceObject(caller Value, nexter TokenScanner, vars Context) (ret Value, err error) {
+func replaceObject(caller Value, args Expr, vars Context) (ret Value, err error) {
 	r := caller.Value.(Object).Copy()
-	err = eachObjectItemForSet(caller, nexter, vars, func(k []byte, val Value) error {
+	err = eachObjectItemForSet(caller, args, vars, func(k []byte, val Value) error {
 		if val.Type != ValueObject {
 			return errors.New("replace only support a object as Value")
 		}
@@ -78,23 +89,20 @@ func replaceObject(caller Value, nexter TokenScanner, vars Context) (ret Value,
 	return
 }
 
-func transObject(caller Value, scanner TokenScanner, ctx Context) (ret Value, err error) {
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
+func transObject(caller Value, args Expr, ctx Context) (ret Value, err error) {
 	o := caller.Copy()
 	ctx.pushMe(o)
 	defer ctx.popMe()
-	stmt := NewStmtExecutor(scanner, ctx)
-	if err = stmt.Execute(); err != nil {
+	if _, err = args.Eval(ctx); err != nil {
 		return
 	}
 	ret = o
 	return
 }
 
-func getObject(caller Value, nexter TokenScanner, vars Context) (ret Value, err error) {
+func getObject(caller Value, args Expr, vars Context) (ret Value, err error) {
 	no := NewObject()
-	err = eachObjectItem(caller, nexter, vars, func(k []byte, val Value) error {
+	err = eachObjectItem(caller, args, vars, func(k []byte, val Value) error {
 		no.Set(k, val)
 		return nil
 	})
@@ -102,9 +110,9 @@ func getObject(caller Value, nexter TokenScanner, vars Context) (ret Value, err
 	return
 }
 
-func delObject(caller Value, nexter TokenScanner, vars Context) (ret Value, err error) {
+func delObject(caller Value, args Expr, vars Context) (ret Value, err error) {
 	r := caller.Value.(Object).Copy()
-	err = eachObjectItem(caller, nexter, vars, func(k []byte, val Value) error {
+	err = eachObjectItem(caller, args, vars, func(k []byte, val Value) error {
 		r.Del(k)
 		return nil
 	})
@@ -112,53 +120,243 @@ func delObject(caller Value, nexter TokenScanner, vars Context) (ret Value, err
 	return
 }
 
-func eachObjectItemForSet(caller Value, nexter TokenScanner, ctx Context, handle func(k []byte, val Value) error) (err error) {
-	scanner := NewCachedTokenScanner(nexter)
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
-	stmt := NewStmtExecutor(scanner, ctx)
+func keysObject(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = methodArgs("object", "keys", args, 0, 0); err != nil {
+		return
+	}
+	keys := NewArray()
+	caller.Value.(Object).Each(func(k []byte, _ Value) bool {
+		keys.items = append(keys.items, StringValue(k...))
+		return true
+	})
+	return ArrayValue(keys), nil
+}
+
+func valuesObject(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = methodArgs("object", "values", args, 0, 0); err != nil {
+		return
+	}
+	values := NewArray()
+	caller.Value.(Object).Each(func(_ []byte, val Value) bool {
+		values.items = append(values.items, val)
+		return true
+	})
+	return ArrayValue(values), nil
+}
+
+// entriesObject the [key, value] pairs of the object, fromEntries of array turns them back
+func entriesObject(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = methodArgs("object", "entries", args, 0, 0); err != nil {
+		return
+	}
+	entries := NewArray()
+	caller.Value.(Object).Each(func(k []byte, val Value) bool {
+		entries.items = append(entries.items, ArrayValue(NewArray(StringValue(k...), val)))
+		return true
+	})
+	return ArrayValue(entries), nil
+}
+
+// pickObject pick(keys) and omit(keys), the keys are an array of strings or the strings
+// as the args, such as pick(["a", "b"]) and pick("a", "b")
+func pickObject(method string, keep bool) Callback {
+	return func(caller Value, args Expr, ctx Context) (ret Value, err error) {
+		var items []Expr
+		if items, err = methodArgs("object", method, args, 1, len(Args(args))); err != nil {
+			return
+		}
+		var keys [][]byte
+		for _, item := range items {
+			var val Value
+			if val, err = item.Eval(ctx); err != nil {
+				return
+			}
+			vals := []Value{val.RealValue()}
+			if vals[0].Type == ValueArray {
+				vals, _ = itemsOf(vals[0])
+			}
+			for _, k := range vals {
+				if k = k.RealValue(); k.Type != ValueString {
+					err = fmt.Errorf("object %s expect the string keys, got [%s]", method, k.TypeName())
+					return
+				}
+				keys = append(keys, k.Bytes())
+			}
+		}
+		r := NewObject()
+		caller.Value.(Object).Each(func(k []byte, val Value) bool {
+			listed := false
+			for _, key := range keys {
+				if listed = bytes.Equal(k, key); listed {
+					break
+				}
+			}
+			if listed == keep {
+				r.Set(k, val)
+			}
+			return true
+		})
+		return ObjectValue(r), nil
+	}
+}
+
+// mapKeysObject mapKeys(expr), expr is evaluated for every pair with k and v as the new key
+func mapKeysObject(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("object", "mapKeys", args, 1, 1); err != nil {
+		return
+	}
+	r := NewObject()
 	ctx.pushMe(caller)
 	defer ctx.popMe()
-	caller.Value.(PairEachable).Each(func(k []byte, val Value) bool {
-		scanner.ResetRead()
-		ctx.Assign([]byte{'k'}, StringValue(k...))
-		ctx.Assign([]byte{'v'}, val)
-		if err = stmt.Execute(); err != nil {
+	caller.Value.(Object).Each(func(k []byte, val Value) bool {
+		var key Value
+		if key, err = evalArgs(items[0], ctx, keyVar(k), valueVar(val)); err != nil {
 			return false
 		}
-		if stmt.Exited() {
-			Exit()
+		if key.Type != ValueString {
+			err = fmt.Errorf("object mapKeys expect a string key, got [%s]", key.TypeName())
+			return false
+		}
+		r.Set(key.Bytes(), val)
+		return true
+	})
+	return ObjectValue(r), err
+}
+
+// mergeObject merge(obj) or merge(obj, strategy), the objects are merged deeply, the other values
+// of obj override, the arrays are replaced by default, or concatenated with the strategy "concat"
+func mergeObject(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("object", "merge", args, 1, 2); err != nil {
+		return
+	}
+	var other Value
+	if other, err = items[0].Eval(ctx); err != nil {
+		return
+	}
+	if other = other.RealValue(); other.Type != ValueObject {
+		err = fmt.Errorf("object merge expect an object, got [%s]", other.TypeName())
+		return
+	}
+	concat := false
+	if len(items) == 2 {
+		var strategy Value
+		if strategy, err = items[1].Eval(ctx); err != nil {
+			return
+		}
+		switch strategy.RealValue().String() {
+		case "replace":
+		case "concat":
+			concat = true
+		default:
+			err = fmt.Errorf("object merge expect the strategy replace or concat, got [%s]", strategy.RealValue().String())
+			return
+		}
+	}
+	return ObjectValue(mergeDeep(caller.Value.(Object), other.Value.(Object), concat)), nil
+}
+
+func mergeDeep(dst, src Object, concat bool) Object {
+	r := dst.Copy()
+	src.Each(func(k []byte, val Value) bool {
+		old := r.Get(k)
+		switch {
+		case old.Type == ValueObject && val.Type == ValueObject:
+			val = ObjectValue(mergeDeep(old.Value.(Object), val.Value.(Object), concat))
+		case concat && old.Type == ValueArray && val.Type == ValueArray:
+			arr := old.Value.(Array).Copy()
+			val.Value.(Array).Each(func(_ int, item Value) bool {
+				arr.Append(item)
+				return true
+			})
+			val = ArrayValue(arr)
+		}
+		r.Set(k, val)
+		return true
+	})
+	return r
+}
+
+func hasObject(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("object", "has", args, 1, 1); err != nil {
+		return
+	}
+	var key Value
+	if key, err = items[0].Eval(ctx); err != nil {
+		return
+	}
+	if key = key.RealValue(); key.Type != ValueString {
+		err = fmt.Errorf("object has expect a string key, got [%s]", key.TypeName())
+		return
+	}
+	return BoolValue(caller.Value.(Object).Has(key.Bytes())), nil
+}
+
+func lenObject(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = methodArgs("object", "len", args, 0, 0); err != nil {
+		return
+	}
+	return IntValue(int64(caller.Value.(Object).Total())), nil
+}
+
+// getPathObject get(path) or get(path, default), the value at the dotted path, such as
+// get("db.hosts.0"), default or null if the value is null
+func getPathObject(caller Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("object", "get", args, 1, 2); err != nil {
+		return
+	}
+	var path Value
+	if path, err = items[0].Eval(ctx); err != nil {
+		return
+	}
+	if path = path.RealValue(); path.Type != ValueString {
+		err = fmt.Errorf("object get expect a string path, got [%s]", path.TypeName())
+		return
+	}
+	if ret = caller.lookup(path.Bytes()).RealValue(); ret.Type != ValueNull || len(items) == 1 {
+		return
+	}
+	if ret, err = items[1].Eval(ctx); err != nil {
+		return
+	}
+	return ret.RealValue(), nil
+}
+
+// eachObjectItemForSet evaluate args for every pair, handle the not null result
+func eachObjectItemForSet(caller Value, args Expr, ctx Context, handle func(k []byte, val Value) error) (err error) {
+	ctx.pushMe(caller)
+	defer ctx.popMe()
+	caller.Value.(PairEachable).Each(func(k []byte, val Value) bool {
+		var p Value
+		if p, err = evalArgs(args, ctx, keyVar(k), valueVar(val)); err != nil {
+			return false
 		}
-		p := stmt.Value()
 		if p.Type == ValueNull {
 			return true
 		}
-		return handle(k, p) == nil
+		err = handle(k, p)
+		return err == nil
 	})
 	return
 }
 
-func eachObjectItem(caller Value, nexter TokenScanner, ctx Context, handle func(k []byte, val Value) error) (err error) {
-	scanner := NewCachedTokenScanner(nexter)
-	nexter.PushEnds(TokenParenthesesClose)
-	defer nexter.PopEnds(TokenParenthesesClose)
-	stmt := NewStmtExecutor(scanner, ctx)
+// eachObjectItem evaluate args as a condition for every pair, handle the matched pairs
+func eachObjectItem(caller Value, args Expr, ctx Context, handle func(k []byte, val Value) error) (err error) {
 	ctx.pushMe(caller)
 	defer ctx.popMe()
 	caller.Value.(PairEachable).Each(func(k []byte, val Value) bool {
-		scanner.ResetRead()
-		ctx.Assign([]byte{'k'}, StringValue(k...))
-		ctx.Assign([]byte{'v'}, val)
-		if err = stmt.Execute(); err != nil {
+		var p Value
+		if p, err = evalArgs(args, ctx, keyVar(k), valueVar(val)); err != nil {
 			return false
 		}
-		if stmt.Exited() {
-			Exit()
-		}
-		if !stmt.value.Bool() {
+		if !p.Bool() {
 			return true
 		}
-		return handle(k, val) == nil
+		err = handle(k, val)
+		return err == nil
 	})
 	return
 }
@@ -294,52 +492,82 @@ func (obj *object) Each(handle func(k []byte, val Value) bool) {
 	}
 }
 
-type objectExecutor struct {
-	scanner TokenScanner
-	ctx     Context
-	value   Value
+type pairExpr struct {
+	pos
+	key Expr
+	val Expr
 }
 
-func newObjectExecutor(scanner TokenScanner, vars Context) *objectExecutor {
-	return &objectExecutor{scanner: scanner, ctx: vars}
+type objectExpr struct {
+	pairs []pairExpr
 }
 
-func (e *objectExecutor) execute() (err error) {
-	e.value, err = e.pairs()
+func (e *objectExpr) Eval(ctx Context) (ret Value, err error) {
+	obj := NewObject()
+	ctx.pushMe(ObjectValue(obj))
+	defer ctx.popMe()
+	var key, val Value
+	for _, p := range e.pairs {
+		if key, err = p.key.Eval(ctx); err != nil {
+			return
+		}
+		if key = key.RealValue(); key.Type != ValueString {
+			err = newError(ErrType, p.pos, fmt.Errorf("object key [%v] must be string", key.Value))
+			return
+		}
+		if val, err = p.val.Eval(ctx); err != nil {
+			return
+		}
+		obj.Set(key.Value.(String).Bytes(), val.RealValue())
+	}
+	ret = ObjectValue(obj)
 	return
 }
 
-func (e *objectExecutor) pairs() (ret Value, err error) {
-	val := NewObject()
-	ret = ObjectValue(val)
-	stmt := NewStmtExecutor(e.scanner, e.ctx)
-	e.ctx.pushMe(ObjectValue(val))
-	defer e.ctx.popMe()
+// parseObject parse the pairs of an object till the close brace
+func parseObject(scanner TokenScanner, opt *option) (ret Expr, err error) {
+	obj := &objectExpr{}
 	for {
-		err = stmt.Execute(For(NullValue()), EndWhen(TokenColon))
-		if stmt.Exited() {
-			Exit()
-		}
-		if err != nil || stmt.value.Type == ValueNull {
+		var key, val *blockExpr
+		if _, err = scanner.Scan(); err != nil {
 			return
 		}
-		if stmt.value.Type != ValueString {
-			err = fmt.Errorf("object key [%v] must be string", stmt.value.Value)
+		p := posOf(scanner.Token())
+		if key, err = parseObjectPart(scanner, opt, TokenColon, TokenBraceClose); err != nil {
 			return
 		}
-		key := stmt.value.Value.(String).Bytes()
-		stmt.Execute(For(NullValue()), EndWhen(TokenComma, TokenBraceClose))
-		if stmt.Exited() {
-			Exit()
+		token := *scanner.Token()
+		if len(key.stmts) == 0 && token.Type == TokenBraceClose {
+			scanner.Forward()
+			break
 		}
-		if err != nil {
+		if token.Type != TokenColon {
+			err = expectError(&token, TokenColon)
 			return
 		}
-		val.Set(key, stmt.value)
-		if e.scanner.EndAt() == TokenBraceClose {
+		scanner.Forward()
+		if val, err = parseObjectPart(scanner, opt, TokenComma, TokenBraceClose); err != nil {
 			return
 		}
+		token = *scanner.Token()
+		if token.Type != TokenComma && token.Type != TokenBraceClose {
+			err = expectError(&token, TokenBraceClose)
+			return
+		}
+		scanner.Forward()
+		obj.pairs = append(obj.pairs, pairExpr{pos: p, key: key, val: val})
+		if token.Type == TokenBraceClose {
+			break
+		}
 	}
+	ret = obj
+	return
+}
+
+func parseObjectPart(scanner TokenScanner, opt *option, ends ...TokenType) (*blockExpr, error) {
+	scanner.PushEnds(ends...)
+	defer scanner.PopEnds(ends...)
+	return parseBlock(scanner, opt)
 }
 
 func (obj *object) lookup(k []byte) Value {
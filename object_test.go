@@ -17,7 +17,11 @@ func TestObject_set(t *testing.T) {
 		{Type: TokenNumber, Raw: []byte{'4'}},
 		{Type: TokenParenthesesClose},
 	}))
-	val, err := setObject(Value{Type: ValueArray, Value: obj}, scanner, NewContext())
+	args, err := parseArgs(scanner, &option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := setObject(Value{Type: ValueArray, Value: obj}, args, NewContext())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -46,7 +50,11 @@ func TestObject_del(t *testing.T) {
 		{Type: TokenString, Raw: []byte{'0'}},
 		{Type: TokenParenthesesClose},
 	}))
-	val, err := delObject(Value{Type: ValueArray, Value: obj}, scanner, NewContext())
+	args, err := parseArgs(scanner, &option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := delObject(Value{Type: ValueArray, Value: obj}, args, NewContext())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -54,3 +62,29 @@ func TestObject_del(t *testing.T) {
 		t.Fatal("del object error")
 	}
 }
+
+func TestObject_methods(t *testing.T) {
+	o := `o = {"a": 1, "b": {"c": [1, 2], "d": "x"}, "e": null};`
+	runCases(t, []stmtCase{
+		{src: o + `[o.keys(), o.values()]`, val: `[["a","b","e"],[1,{"c":[1,2],"d":"x"},null]]`},
+		{src: o + `o.entries()`, val: `[["a",1],["b",{"c":[1,2],"d":"x"}],["e",null]]`},
+		{src: o + `o.entries().filter(v.0 != "b").fromEntries()`, val: `{"a":1,"e":null}`},
+		{src: `[["a", 1], ["b"]].fromEntries()`, shoulderr: true},
+		{src: `[[1, 1]].fromEntries()`, shoulderr: true},
+		{src: o + `[o.pick(["e", "a"]), o.pick("a", "z"), o.omit("b", "e"), o.omit(["a"]).keys()]`, val: `[{"a":1,"e":null},{"a":1},{"a":1},["b","e"]]`},
+		{src: o + `o.pick(1)`, shoulderr: true},
+		{src: o + `o.mapKeys("k_" + k).keys()`, val: `["k_a","k_b","k_e"]`},
+		{src: o + `o.mapKeys(1)`, shoulderr: true},
+		{src: o + `o.merge({"b": {"c": [3], "f": 2}, "g": 1})`, val: `{"a":1,"b":{"c":[3],"d":"x","f":2},"e":null,"g":1}`},
+		{src: o + `o.merge({"b": {"c": [3]}}, "concat").b`, val: `{"c":[1,2,3],"d":"x"}`},
+		{src: o + `o.merge({"b": 1}, "replace").b`, val: `1`},
+		{src: o + `o.merge({}, "deep")`, shoulderr: true},
+		{src: o + `o.merge([])`, shoulderr: true},
+		// the merged objects aren't changed
+		{src: o + `m = o.merge({"b": {"c": [3]}}, "concat"); o.b.c`, val: `[1,2]`},
+		{src: o + `[o.has("e"), o.has("z"), o.len(), {}.len()]`, val: `[true,false,3,0]`},
+		{src: o + `[o.get("b.c.1"), o.get("b.c.5", 9), o.get("z.y", "def"), o.get("e", 0), o.get("a")]`, val: `[2,9,"def",0,1]`},
+		{src: `{"x": [1]} + {"y": [2]}`, val: `{"x":[1],"y":[2]}`},
+		{src: `{"a": 1, "b": 2}.set(k == "a" => 3)`, val: `{"a":3,"b":2}`},
+	})
+}
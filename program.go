@@ -0,0 +1,310 @@
+package djson
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+)
+
+const defaultBufSize = 512
+
+// Program a syntax tree compiled from the djson source, the tree is built only once and can be
+// evaluated many times and from many goroutines
+type Program struct {
+	block  *blockExpr
+	file   string  // name of the source file
+	src    []byte  // the source, for the excerpt of the errors
+	limits *Limits // the limits of the evaluation, nil if no limit
+}
+
+// Compile parse the djson source to a Program
+func Compile(r io.Reader, opts ...StmtOption) (*Program, error) {
+	return compile(r, defaultBufSize, opts...)
+}
+
+func compile(r io.Reader, bufSize uint, opts ...StmtOption) (*Program, error) {
+	if bufSize == 0 {
+		bufSize = defaultBufSize
+	}
+	opt := &option{}
+	for _, apply := range opts {
+		apply(opt)
+	}
+	var src bytes.Buffer
+	block, err := parseBlock(NewTokenScanner(NewLexer(io.TeeReader(r, &src), bufSize)), opt)
+	if err != nil {
+		locate(err, opt.file, src.Bytes())
+		return nil, err
+	}
+	return &Program{block: block, file: opt.file, src: src.Bytes(), limits: opt.limits}, nil
+}
+
+// Eval evaluate the Program within the Context and return the value of the last stmt,
+// the variables assigned by the Program live in a new scope of the Context, so a Context
+// preseeded can be shared by many evaluations
+func (p *Program) Eval(ctx Context) (val Value, err error) {
+	return p.EvalContext(context.Background(), ctx)
+}
+
+// EvalContext evaluate the Program like Eval, the evaluation stops with the error of goctx
+// once goctx is done
+func (p *Program) EvalContext(goctx context.Context, ctx Context) (val Value, err error) {
+	if ctx == nil {
+		ctx = NewContext()
+	} else {
+		ctx = ctx.Copy()
+	}
+	val, _, err = p.eval(goctx, ctx, nil)
+	locate(err, p.file, p.src)
+	return
+}
+
+// Stream evaluate the Program like EvalContext, but if the last stmt maps a range, such as
+// `users = [1 ... 10000].map({...}).map(...)`, the elements are passed to handle one by one
+// instead of building the array, and the maps chained are applied to each element in order.
+// streamed reports whether the elements were passed to handle
+func (p *Program) Stream(goctx context.Context, ctx Context, handle func(i int, val Value) error) (val Value, streamed bool, err error) {
+	if ctx == nil {
+		ctx = NewContext()
+	} else {
+		ctx = ctx.Copy()
+	}
+	last := len(p.block.stmts) - 1
+	val, _, err = p.eval(goctx, ctx, func(ctx Context, i int, s Expr) (Value, error) {
+		rg, maps, ok := streamOf(s)
+		if i != last || !ok {
+			return s.Eval(ctx)
+		}
+		streamed = true
+		return NullValue(), streamRange(ctx, rg, maps, handle)
+	})
+	locate(err, p.file, p.src)
+	return
+}
+
+// Documents evaluate the Program like EvalContext, and pass the documents to handle in order,
+// the documents are the values of emit, and the non-null values of the top-level stmts which
+// are neither assignments nor emit anything
+func (p *Program) Documents(goctx context.Context, ctx Context, handle func(i int, val Value) error) (err error) {
+	if ctx == nil {
+		ctx = NewContext()
+	} else {
+		ctx = ctx.Copy()
+	}
+	var mu sync.Mutex
+	total := 0
+	// emit may be evaluated in the goroutines of parallel
+	emit := func(val Value) error {
+		mu.Lock()
+		defer mu.Unlock()
+		total++
+		return handle(total-1, val)
+	}
+	ctx.evalState().emit = emit
+	_, _, err = p.eval(goctx, ctx, func(ctx Context, _ int, s Expr) (val Value, err error) {
+		emitted := total
+		if val, err = s.Eval(ctx); err != nil {
+			return
+		}
+		if _, isAssign := s.(*assignExpr); isAssign || emitted != total {
+			return
+		}
+		if doc := val.RealValue(); doc.Type != ValueNull && doc.Type != ValueReturn {
+			err = emit(doc)
+		}
+		return
+	})
+	locate(err, p.file, p.src)
+	return
+}
+
+// eval evaluate the stmts, the stmts are evaluated by evalStmt if it's not nil
+func (p *Program) eval(goctx context.Context, ctx Context, evalStmt func(ctx Context, i int, s Expr) (Value, error)) (val Value, exited bool, err error) {
+	ctx.PushScope()
+	defer ctx.PopScope()
+	state := ctx.evalState()
+	prev := *state
+	defer func() {
+		*state = prev
+	}()
+	state.goctx = goctx
+	if p.file != "" {
+		state.imports = append(append([]string{}, state.imports...), resolveImport("", p.file))
+	}
+	if p.limits != nil || goctx.Done() != nil {
+		var limits Limits
+		if p.limits != nil {
+			limits = *p.limits
+		}
+		l := newLimiter(goctx, limits)
+		defer l.stop()
+		state.limiter, state.goctx = l, l.ctx
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok && errors.Is(e, errExit) {
+				exited = true
+				return
+			}
+			panic(r)
+		}
+	}()
+	var v Value
+	for i, s := range p.block.stmts {
+		if err = ctx.evalState().limiter.step(); err != nil {
+			return
+		}
+		if evalStmt != nil {
+			v, err = evalStmt(ctx, i, s)
+		} else {
+			v, err = s.Eval(ctx)
+		}
+		if err != nil {
+			return
+		}
+		if v.Type == ValueReturn {
+			return
+		}
+		val = v
+	}
+	return
+}
+
+// module evaluate the Program imported by the host in an isolated Context, which shares the
+// evaluation state with the host, the variables declared by the Program are returned as an Object
+func (p *Program) module(host Context) (obj Object, err error) {
+	ctx := host.isolated()
+	state := ctx.evalState()
+	state.imports = append(append([]string{}, state.imports...), p.file)
+	ctx.PushScope()
+	func() {
+		// exit ends the imported Program only, the variables declared before it are exported
+		defer func() {
+			if r := recover(); r != nil {
+				if e, ok := r.(error); ok && errors.Is(e, errExit) {
+					return
+				}
+				panic(r)
+			}
+		}()
+		var v Value
+		for _, s := range p.block.stmts {
+			if err = state.limiter.step(); err != nil {
+				return
+			}
+			if v, err = s.Eval(ctx); err != nil || v.Type == ValueReturn {
+				return
+			}
+		}
+	}()
+	if err != nil {
+		locate(err, p.file, p.src)
+		return
+	}
+	exports := NewObject()
+	for _, v := range ctx.scope.vars {
+		exports.Set(v.Name, v.Value)
+	}
+	return exports, nil
+}
+
+// streamOf match the stmt like `[a ... b].map(...).map(...)`, which is optionally assigned to a variable
+func streamOf(s Expr) (rg *rangeExpr, maps []*callExpr, ok bool) {
+	if assign, isAssign := s.(*assignExpr); isAssign {
+		s = assign.right
+	}
+	for {
+		call, isCall := s.(*callExpr)
+		if !isCall {
+			break
+		}
+		member, isMember := call.fn.(*memberExpr)
+		if !isMember || string(member.name) != "map" {
+			return
+		}
+		maps = append([]*callExpr{call}, maps...)
+		s = member.left
+	}
+	if len(maps) == 0 {
+		return
+	}
+	// [a ... b] and (a ... b)
+	if arr, isArray := s.(*arrayExpr); isArray {
+		if len(arr.items) != 1 {
+			return
+		}
+		s = arr.items[0]
+	}
+	if _, isBlock := s.(*blockExpr); !isBlock {
+		return
+	}
+	// _me of the maps chained is the array mapped by the previous map, which isn't built when streaming
+	for _, m := range maps[1:] {
+		if mentions(m.args, []byte("_me")) {
+			return
+		}
+	}
+	rg, ok = rangeOf(s)
+	return
+}
+
+// mentions whether the identifier name appears in the syntax tree of e
+func mentions(e Expr, name []byte) bool {
+	identType := reflect.TypeOf(&identExpr{})
+	var walk func(v reflect.Value) bool
+	walk = func(v reflect.Value) bool {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr:
+			if v.IsNil() {
+				return false
+			}
+			if v.Type() == identType && bytes.Equal(v.Elem().FieldByName("name").Bytes(), name) {
+				return true
+			}
+			return walk(v.Elem())
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if walk(v.Field(i)) {
+					return true
+				}
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				if walk(v.Index(i)) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return walk(reflect.ValueOf(e))
+}
+
+// streamRange apply the maps to each item of the range, and pass the results to handle, the first
+// map maps the range and the others map the arrays, which keep the item if the result is null
+func streamRange(ctx Context, rg *rangeExpr, maps []*callExpr, handle func(i int, val Value) error) (err error) {
+	var val Value
+	if val, err = rg.Eval(ctx); err != nil {
+		return
+	}
+	ctx.pushMe(val)
+	defer ctx.popMe()
+	val.Value.(*range_).Each(func(i int, v Value) bool {
+		for j, m := range maps {
+			var p Value
+			if p, err = evalArgs(m.args, ctx, indexVar(i), valueVar(v)); err != nil {
+				err = wrapError(ErrRuntime, m.pos, err)
+				return false
+			}
+			if j == 0 || p.Type != ValueNull {
+				v = p
+			}
+		}
+		err = handle(i, v)
+		return err == nil
+	})
+	return
+}
@@ -0,0 +1,315 @@
+package djson
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProgram_eval(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`
+a = [1 ... 4].map(v * 2).map(v + 1);
+b = {"x": 1, "y": [1, 2,]}.map(k == "x" => v + a.0);
+[a, b, false || true, 1 ... 3]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		val, err := prog.Eval(NewContext())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := jsonString(val); got != `[[3,5,7],{"x":4,"y":[1,2]},true,1,2]` {
+			t.Fatalf("range in array should be spread, got %s", got)
+		}
+	}
+	prog, err = Compile(strings.NewReader(`
+a = [1 ... 4].map(v * 2).map(v + 1);
+b = {"x": 1, "y": [1, 2,]}.map(k == "x" => v + a.0);
+[a, b, false || true, {}]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := prog.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := val.Value.(Array)
+	if arr.Total() != 4 {
+		t.Fatal("array total error")
+	}
+	if arr.Get(0).Value.(Array).Get(2).MustInt() != 7 {
+		t.Fatal("chained map error")
+	}
+	if arr.Get(1).Value.(Object).Get([]byte{'x'}).MustInt() != 4 {
+		t.Fatal("object map error")
+	}
+	if arr.Get(1).Value.(Object).Get([]byte{'y'}).Value.(Array).Total() != 2 {
+		t.Fatal("trailing comma error")
+	}
+	if !arr.Get(2).Bool() {
+		t.Fatal("or error")
+	}
+	if arr.Get(3).Value.(Object).Total() != 0 {
+		t.Fatal("empty object error")
+	}
+}
+
+func TestProgram_evalConcurrently(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`
+users = [0 ... 100].map({"name": "user" + v, "i": i});
+users.filter(v.i % 2 == n)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			val, err := prog.Eval(NewContext(Variable{Name: []byte{'n'}, Value: IntValue(int64(n % 2))}))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			arr := val.Value.(Array)
+			if arr.Total() != 50 || arr.Get(0).Value.(Object).Get([]byte{'i'}).MustInt() != int64(n%2) {
+				t.Error("filter error")
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+func TestProgram_sharedContext(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`env = env + "x"; added = 1; env`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext(Variable{Name: []byte("env"), Value: StringValue([]byte("e")...)})
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				val, err := prog.Eval(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if val.String() != "ex" {
+					t.Errorf("the run should see the preseeded env, got %s", val.String())
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := ctx.ValueOf([]byte("env")).String(); got != "e" {
+		t.Fatalf("the caller's Context changed, env is %s", got)
+	}
+	if ctx.ValueOf([]byte("added")).Type != ValueNull || len(ctx.All()) != 1 {
+		t.Fatal("the variables of the runs leaked to the caller's Context")
+	}
+}
+
+func TestProgram_sharedContainers(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`o.a = o.a + 1; o.list.0 = o.a; list.1 = "x"; [o, list]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, _ := FromJSON([]byte(`{"a": 1, "list": [0, 1]}`))
+	list, _ := FromJSON([]byte(`[{"b": 1}, 2]`))
+	ctx := NewContext(Variable{Name: []byte("o"), Value: o}, Variable{Name: []byte("list"), Value: list})
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				val, err := prog.Eval(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got := jsonString(val); got != `[{"a":2,"list":[2,1]},[{"b":1},"x"]]` {
+					t.Errorf("each run should see the preseeded values, got %s", got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := jsonString(ctx.ValueOf([]byte("o"))) + jsonString(ctx.ValueOf([]byte("list"))); got != `{"a":1,"list":[0,1]}[{"b":1},2]` {
+		t.Fatalf("the caller's values changed: %s", got)
+	}
+}
+
+func TestProgram_exit(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`a = 1; a; true => exit; 2`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := prog.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.MustInt() != 1 {
+		t.Fatal("exit error")
+	}
+}
+
+func TestProgram_scope(t *testing.T) {
+	// the vars of map don't leak to the outer scope
+	prog, err := Compile(strings.NewReader(`v = "outer"; [1 ... 3].map([1 ... 2].map(v)); v`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := prog.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.String() != "outer" {
+		t.Fatal("scope error")
+	}
+}
+
+func TestProgram_stream(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`n = 3; users = [0 ... n].map({"i": i}).map(v + {"v": v.i * 2})`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []Value
+	_, streamed, err := prog.Stream(context.Background(), nil, func(i int, val Value) error {
+		if i != len(items) {
+			t.Fatal("index error")
+		}
+		items = append(items, val)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !streamed || len(items) != 3 {
+		t.Fatal("stream error")
+	}
+	if items[2].Value.(Object).Get([]byte("v")).MustInt() != 4 {
+		t.Fatal("chained map error")
+	}
+	// not a mapped range
+	prog, _ = Compile(strings.NewReader(`[1 ... 3].map(v); 5`))
+	val, streamed, err := prog.Stream(context.Background(), nil, func(int, Value) error {
+		t.Fatal("should not stream")
+		return nil
+	})
+	if err != nil || streamed || val.MustInt() != 5 {
+		t.Fatal("fallback error")
+	}
+	// the error of handle stops the stream
+	prog, _ = Compile(strings.NewReader(`[1 ... 100].map(v)`))
+	calls := 0
+	stop := errors.New("stop")
+	if _, _, err = prog.Stream(context.Background(), nil, func(int, Value) error {
+		calls++
+		return stop
+	}); !errors.Is(err, stop) || calls != 1 {
+		t.Fatal("handle error should stop the stream")
+	}
+}
+
+func TestProgram_streamSameOutput(t *testing.T) {
+	data := []string{
+		`[0 ... 3].map(v).map(v > 0 => 10)`,
+		`[0 ... 3].map(v > 0 => v).map(v)`,
+		`[0 ... 4].map({"i": i}).map(v.i % 2 == 0 => v + {"even": true}).map(v + {"n": i})`,
+		`[0 ... 3].map(v * 2).map(_me.len() + v)`,
+		`[0 ... 3].map(_me.len() + v).map(v * 2)`,
+		`xs = [3 ..= 0 : 2].map(null).map(1)`,
+	}
+	for i, src := range data {
+		prog, err := Compile(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, err := prog.Eval(nil)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		items := []Value{}
+		ret, streamed, err := prog.Stream(context.Background(), nil, func(_ int, val Value) error {
+			items = append(items, val)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if streamed {
+			ret = ArrayValue(NewArray(items...))
+		}
+		if expect, got := jsonString(val), jsonString(ret); expect != got {
+			t.Fatalf("%d: expect %s, got %s when streaming", i, expect, got)
+		}
+	}
+}
+
+func BenchmarkProgram_config(b *testing.B) {
+	prog, err := Compile(strings.NewReader(`
+# a config test
+
+users = [1 ... 10000].map({
+    "username": "user" + v,
+    "password": "password" + i,
+    "i": i
+}).map(v + {"odd": v.i%2 != 0});
+    `))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := prog.Eval(NewContext()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestProgram_documents(t *testing.T) {
+	prog, err := Compile(strings.NewReader(`
+name = "app";
+{"name": name};
+null;
+emit {"kind": "service"};
+[1 ... 3].each(emit {"replica": v});
+true => exit;
+"after exit"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []string
+	if err = prog.Documents(context.Background(), nil, func(i int, val Value) error {
+		if i != len(docs) {
+			t.Fatal("index error")
+		}
+		docs = append(docs, val.String())
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 4 {
+		t.Fatalf("documents error: %v", docs)
+	}
+	// emit is an expression out of the documents mode
+	prog, _ = Compile(strings.NewReader(`a = emit 1 + 2; a * 2`))
+	val, err := prog.Eval(nil)
+	if err != nil || val.MustInt() != 6 {
+		t.Fatal("emit value error")
+	}
+	if _, err = Compile(strings.NewReader(`emit;`)); err == nil {
+		t.Fatal("emit without a value should fail")
+	}
+}
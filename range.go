@@ -1,135 +1,232 @@
 package djson
 
-import "sync"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+)
 
 type ItemEachable interface {
 	Each(func(i int, val Value) bool)
 }
 
 type range_ struct {
-	from int
-	to   int
+	from      int
+	to        int
+	step      int  // the distance between the items, always greater than 0
+	inclusive bool // whether to is included if it's on the step
 	*CallableRegister
 }
 
 func NewRange(from, to int) *range_ {
+	return newRange(from, to, 1, false)
+}
+
+// newRange a range from from to to by step, it counts down if to is less than from
+func newRange(from, to, step int, inclusive bool) *range_ {
 	rg := &range_{
 		from:             from,
 		to:               to,
+		step:             step,
+		inclusive:        inclusive,
 		CallableRegister: NewCallableRegister("range"),
 	}
 	rg.RegisterCall("map", mapRange)
 	rg.RegisterCall("parallel", mapRangeParallelly)
 	rg.RegisterCall("each", eachRange)
+	rg.RegisterCall("filter", filterArray)
+	rg.RegisterCall("reduce", reduceItems)
+	rg.RegisterCall("take", takeRange)
+	rg.RegisterCall("len", lenRange)
 	return rg
 }
 
-func mapRange(val Value, scanner TokenScanner, ctx Context) (ret Value, err error) {
-	resetableScanner := NewCachedTokenScanner(scanner)
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
-	stmt := NewStmtExecutor(resetableScanner, ctx)
+func lenRange(val Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = methodArgs("range", "len", args, 0, 0); err != nil {
+		return
+	}
+	return IntValue(int64(val.Value.(*range_).Total())), nil
+}
+
+// takeRange take(n), the range of the first n items, the items aren't built
+func takeRange(val Value, args Expr, ctx Context) (ret Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("range", "take", args, 1, 1); err != nil {
+		return
+	}
+	var n Value
+	if n, err = items[0].Eval(ctx); err != nil {
+		return
+	}
+	if n = n.RealValue(); n.Type != ValueInt {
+		err = fmt.Errorf("range take expect an int, got [%s]", n.TypeName())
+		return
+	}
+	count, _ := n.Int()
+	if count < 0 {
+		err = fmt.Errorf("range take expect a count >= 0, got %d", count)
+		return
+	}
+	rg := val.Value.(*range_)
+	if int(count) >= rg.Total() {
+		return val, nil
+	}
+	to := rg.from + rg.direction()*rg.step*int(count)
+	return Value{Type: ValueRange, Value: newRange(rg.from, to, rg.step, false)}, nil
+}
+
+func mapRange(val Value, args Expr, ctx Context) (ret Value, err error) {
 	ctx.pushMe(val)
 	defer ctx.popMe()
 	r := NewArrayWithLength(val.Value.(*range_).Total())
 	val.Value.(ItemEachable).Each(func(i int, val Value) bool {
-		resetableScanner.ResetRead()
-		stmt.AssignVar([]byte{'i'}, IntValue(int64(i)))
-		stmt.AssignVar([]byte{'v'}, val)
-		if err = stmt.Execute(For(NullValue())); err != nil {
+		var p Value
+		if p, err = evalArgs(args, ctx, indexVar(i), valueVar(val)); err != nil {
 			return false
 		}
-		if stmt.Exited() {
-			Exit()
-		}
-		r.Set(i, stmt.value)
+		r.Set(i, p)
 		return true
 	})
 	ret = Value{Type: ValueArray, Value: r}
 	return
 }
 
-func mapRangeParallelly(val Value, scanner TokenScanner, ctx Context) (ret Value, err error) {
-	cachedScanner := NewCachedTokenScanner(scanner)
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
-	if err = cachedScanner.CacheToEnd(); err != nil {
-		return
-	}
+// mapRangeParallelly map the items by the workers in parallel, one worker an item, or as many
+// as the Goroutines of the Limits allow, it fails only if no worker is available
+func mapRangeParallelly(val Value, args Expr, ctx Context) (ret Value, err error) {
 	ctx.pushMe(val)
 	defer ctx.popMe()
-	total := val.Value.(*range_).Total()
+	rg := val.Value.(*range_)
+	total := rg.Total()
 	r := NewArrayWithLength(total)
+	ret = Value{Type: ValueArray, Value: r}
+	if total == 0 {
+		return
+	}
+	limiter := ctx.evalState().limiter
+	workers := limiter.acquire(total)
+	if workers == 0 {
+		return NullValue(), &LimitError{Kind: LimitGoroutines, Max: int64(limiter.Goroutines)}
+	}
+	defer limiter.release(workers)
 	var wg sync.WaitGroup
-	wg.Add(total)
+	wg.Add(workers)
 	var lock sync.Mutex
-	val.Value.(ItemEachable).Each(func(i int, val Value) bool {
+	var exited bool
+	next := int64(-1)
+	for w := 0; w < workers; w++ {
 		go func() {
 			defer wg.Done()
-			localCtx := ctx.Copy()
-			stmt := NewStmtExecutor(cachedScanner.Copy(), localCtx)
-			cachedScanner.ResetRead()
-			stmt.AssignVar([]byte{'i'}, IntValue(int64(i)))
-			stmt.AssignVar([]byte{'v'}, val)
-			if e := stmt.Execute(For(NullValue())); e != nil {
+			defer func() {
+				if r := recover(); r != nil {
+					if e, ok := r.(error); ok && errors.Is(e, errExit) {
+						lock.Lock()
+						exited = true
+						lock.Unlock()
+						return
+					}
+					panic(r)
+				}
+			}()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
 				lock.Lock()
-				err = e
+				stop := i >= total || err != nil || exited
+				lock.Unlock()
+				if stop {
+					return
+				}
+				p, e := evalArgs(args, ctx.fork(), indexVar(i), valueVar(rg.at(i)))
+				lock.Lock()
+				if e != nil && err == nil {
+					err = e
+				}
+				r.Set(i, p)
 				lock.Unlock()
-				return
-			}
-			if stmt.Exited() {
-				Exit()
 			}
-			lock.Lock()
-			r.Set(i, stmt.value)
-			ctx.Merge(localCtx)
-			lock.Unlock()
 		}()
-		return true
-	})
-	if err != nil {
-		return
 	}
-	scanner.Forward()
 	wg.Wait()
-	ret = Value{Type: ValueArray, Value: r}
+	if exited {
+		Exit()
+	}
+	if err != nil {
+		return NullValue(), err
+	}
 	return
 }
 
-func eachRange(val Value, scanner TokenScanner, ctx Context) (ret Value, err error) {
-	resetableScanner := NewCachedTokenScanner(scanner)
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
-	stmt := NewStmtExecutor(resetableScanner, ctx)
+func eachRange(val Value, args Expr, ctx Context) (ret Value, err error) {
 	ctx.pushMe(val)
 	defer ctx.popMe()
 	val.Value.(ItemEachable).Each(func(i int, val Value) bool {
-		resetableScanner.ResetRead()
-		stmt.AssignVar([]byte{'i'}, IntValue(int64(i)))
-		stmt.AssignVar([]byte{'v'}, val)
-		if err = stmt.Execute(For(NullValue())); err != nil {
-			return false
-		}
-		if stmt.Exited() {
-			Exit()
-		}
-		return true
+		_, err = evalArgs(args, ctx, indexVar(i), valueVar(val))
+		return err == nil
 	})
 	return
 }
 
 func (arr *range_) Each(handle func(i int, val Value) bool) {
-	for i := arr.from; i < arr.to; i++ {
-		if !handle(i-arr.from, IntValue(int64(i))) {
+	total := arr.Total()
+	for i := 0; i < total; i++ {
+		if !handle(i, arr.at(i)) {
 			break
 		}
 	}
 }
 
+// at the ith item of the range
+func (arr *range_) at(i int) Value {
+	return IntValue(int64(arr.from + i*arr.direction()*arr.step))
+}
+
 func (arr *range_) Copy() *range_ {
-	return &range_{from: arr.from, to: arr.to}
+	return newRange(arr.from, arr.to, arr.step, arr.inclusive)
 }
 
+// Total the number of the items, the length must fit an int, which rangeExpr checks by length
 func (arr *range_) Total() int {
-	return arr.to - arr.from
+	n, _ := arr.length()
+	return int(n)
+}
+
+// length the number of the items computed in uint64, ok is false if it doesn't fit an int
+func (arr *range_) length() (n uint64, ok bool) {
+	// the distance of two ints always fits an uint64
+	var distance uint64
+	if arr.to < arr.from {
+		distance = uint64(arr.from) - uint64(arr.to)
+	} else {
+		distance = uint64(arr.to) - uint64(arr.from)
+	}
+	step := uint64(arr.step)
+	n = distance / step
+	if arr.inclusive {
+		if n == math.MaxUint64 {
+			return n, false
+		}
+		n++
+	} else if distance%step != 0 {
+		n++
+	}
+	return n, n <= math.MaxInt
+}
+
+// direction 1 if the range counts up, -1 if down
+func (arr *range_) direction() int {
+	if arr.to < arr.from {
+		return -1
+	}
+	return 1
+}
+
+// encodable the value to encode, a range is encoded as the array of its items
+func encodable(val Value) Value {
+	if val = val.RealValue(); val.Type == ValueRange {
+		items, _ := itemsOf(val)
+		return ArrayValue(NewArray(items...))
+	}
+	return val
 }
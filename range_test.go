@@ -1,6 +1,7 @@
 package djson
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
@@ -57,6 +58,56 @@ func TestRange_parallelMap(t *testing.T) {
 	t.Log(stmt.Value())
 }
 
+func TestRange_step(t *testing.T) {
+	runCases(t, []stmtCase{
+		{src: "[10 ... 5]", val: `[10,9,8,7,6]`},
+		{src: "[0 ... 10 : 3]", val: `[0,3,6,9]`},
+		{src: "[10 ... 0 : 4]", val: `[10,6,2]`},
+		{src: "[0 ..= 3]", val: `[0,1,2,3]`},
+		{src: "[3 ..= 0 : 3]", val: `[3,0]`},
+		{src: "[2 ... 2]", val: `[]`},
+		{src: "[2 ..= 2]", val: `[2]`},
+		{src: "[0 ... 10 : 2].map(i * 100 + v)", val: `[0,102,204,306,408]`},
+		{src: "[0 ... 10].filter(v % 3 == 0)", val: `[0,3,6,9]`},
+		{src: "[1 ..= 4].reduce(acc * v)", val: `24`},
+		{src: "[1 ... 4].reduce(acc + v, 10)", val: `16`},
+		{src: "[0 ... 1000000 : 7].take(3)", val: `[0,7,14]`},
+		{src: "[0 ... 1000000].take(2).map(v + 1)", val: `[1,2]`},
+		{src: "[0 ... 100 : 5].len()", val: `20`},
+		{src: "[0, 1 ... 3, 9]", val: `[0,1,2,9]`},
+		{src: "r = [1 ... 3]; [r, 5]", val: `[[1,2],5]`},
+		{src: "1 > 0 ? 0 ... 3 : 5", val: `[0,1,2]`},
+		{src: "{\"a\": 0 ... 10 : 5}", val: `{"a":[0,5]}`},
+		{src: "[0 ... 9223372036854775807 : 2].len()", val: `4611686018427387904`},
+		{src: "[0 ..= 9223372036854775807 : 2].len()", val: `4611686018427387904`},
+		{src: "[0 ... 9223372036854775807].len()", val: `9223372036854775807`},
+		{src: "[9223372036854775807 ... 0 : 9223372036854775807].len()", val: `1`},
+		{src: "m = 0 - 9223372036854775807 - 1; [m ..= 9223372036854775807 : 9223372036854775807]", val: `[-9223372036854775808,-1,9223372036854775806]`},
+		{src: "m = 0 - 9223372036854775807 - 1; [9223372036854775807 ... m : 9223372036854775807].take(2)", val: `[9223372036854775807,0]`},
+	})
+}
+
+func TestRange_stepError(t *testing.T) {
+	data := []struct {
+		src      string
+		category ErrorCategory
+	}{
+		{src: "[0 ... 10 : 0]", category: ErrRuntime},
+		{src: "[0 ... 10 : 0 - 1]", category: ErrRuntime},
+		{src: "[0 ... 10 : \"a\"]", category: ErrType},
+		{src: "[0 ..= 9223372036854775807]", category: ErrRuntime},
+		{src: "m = 0 - 9223372036854775807 - 1; [m ... 9223372036854775807]", category: ErrRuntime},
+		{src: "m = 0 - 9223372036854775807 - 1; [9223372036854775807 ..= m].len()", category: ErrRuntime},
+	}
+	for i, item := range data {
+		_, err := evalSource(item.src)
+		var e *Error
+		if !errors.As(err, &e) || e.Category != item.category {
+			t.Fatalf("%d: expect a %v error, got %v", i, item.category, err)
+		}
+	}
+}
+
 func BenchmarkRange_parallelMap(b *testing.B) {
 	data := `
 # range parallel map test
@@ -2,6 +2,7 @@ package djson
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -12,44 +13,51 @@ var (
 )
 
 type stmt struct {
-	next    *stmt                                                                           // next stmt should try, the priority of this stmt is always lower than next
-	name    string                                                                          // stmt name
-	handle  func(val Value, ctx Context, token *Token) (handled bool, ret Value, err error) // match token and handle the stmt
-	scanner TokenScanner                                                                    // scanner
-	opt     *option                                                                         // opt
+	next    *stmt                                                             // next stmt should try, the priority of this stmt is always lower than next
+	name    string                                                            // stmt name
+	handle  func(left Expr, token *Token) (handled bool, ret Expr, err error) // match token and build the node of the stmt
+	scanner TokenScanner                                                      // scanner
+	opt     *option                                                           // opt
 }
 
-func (e *stmt) Value(val Value, ctx Context) (ret Value, err error) {
+// Parse build the syntax tree node from the scanner, left is the node already built before this stmt
+func (e *stmt) Parse(left Expr) (ret Expr, err error) {
 	terminal := e.next == nil
 	var matched, end, nextTried bool
-	var ht Value
+	var ht Expr
+	ret = left
 	for {
 		if end, err = e.scanner.Scan(); err != nil || end {
 			return
 		}
 		if terminal {
-			_, ret, err = e.handle(val, ctx, e.scanner.Token())
+			// a node built already can't be extended by the terminal
+			if left != nil {
+				ret = left
+				return
+			}
+			_, ret, err = e.handle(left, e.scanner.Token())
 			if e.opt.debug {
 				fmt.Printf("%s\n", e.name)
 			}
 			return
 			// try this level
-		} else if matched, ht, err = e.handle(val, ctx, e.scanner.Token()); err != nil {
+		} else if matched, ht, err = e.handle(left, e.scanner.Token()); err != nil {
 			return
 		} else if matched {
 			nextTried = true
-			val = ht
-			ret = val
+			left = ht
+			ret = left
 			if !e.opt.debug {
 				continue
 			}
 			fmt.Printf("%s\n", e.name)
 		} else if !nextTried {
 			// try higher priorities
-			if val, err = e.next.Value(val, ctx); err != nil {
+			if left, err = e.next.Parse(left); err != nil {
 				return
 			}
-			ret = val
+			ret = left
 			nextTried = true
 		} else {
 			return
@@ -57,28 +65,36 @@ func (e *stmt) Value(val Value, ctx Context) (ret Value, err error) {
 	}
 }
 
+// right parse the right side of a binary operator
+func (e *stmt) right(token *Token) (right Expr, err error) {
+	op := *token
+	e.scanner.Forward()
+	if right, err = e.next.Parse(nil); err != nil {
+		return
+	}
+	if right == nil {
+		err = newError(ErrParse, posOf(&op), fmt.Errorf("missing right side of [%s]", op.Name()))
+	}
+	return
+}
+
 func Exit() {
 	panic(errExit)
 }
 
 func Assign(scanner TokenScanner) *stmt {
 	e := &stmt{scanner: scanner, name: "Assign"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		if token.Type != TokenAssignation {
+	e.handle = func(left Expr, token *Token) (matched bool, ret Expr, err error) {
+		if left == nil || token.Type != TokenAssignation {
 			return
 		}
 		matched = true
-		e.scanner.Forward()
-		var right Value
-		if right, err = e.next.Value(NullValue(), ctx); err != nil {
-			return
-		}
-		if val.Type != ValueIdentifier {
-			err = errors.New("only identifier can assign to")
+		p := posOf(token)
+		var right Expr
+		if right, err = e.right(token); err != nil {
 			return
 		}
-		err = val.Value.(Identifier).Assign(right)
-		ret = right
+		ret = &assignExpr{pos: p, left: left, right: right}
 		return
 	}
 	return e
@@ -86,107 +102,89 @@ func Assign(scanner TokenScanner) *stmt {
 
 func Reduction(scanner TokenScanner) *stmt {
 	e := &stmt{scanner: scanner, name: "Reduction"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		if token.Type != TokenReduction {
+	e.handle = func(left Expr, token *Token) (matched bool, ret Expr, err error) {
+		if left == nil || (token.Type != TokenReduction && token.Type != TokenArrow) {
 			return
 		}
 		matched = true
-		e.scanner.Forward()
-		var right Value
-		if right, err = e.next.Value(NullValue(), ctx); err != nil {
+		p, arrow := posOf(token), token.Type == TokenArrow
+		var right Expr
+		if right, err = e.right(token); err != nil {
 			return
 		}
-		if val.Bool() {
-			ret = right
+		// (a, b) -> body defines a function, otherwise -> is the same as =>
+		if params, ok := paramsOf(left); ok && arrow {
+			ret = &funcExpr{pos: p, params: params, body: right}
+			return
 		}
+		ret = &reductionExpr{cond: left, val: right}
 		return
 	}
 	return e
 }
 
-func Or(scanner TokenScanner) *stmt {
-	e := &stmt{scanner: scanner, name: "Or"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		if token.Type != TokenOr {
+// Ternary cond ? a : b, it's right associative, so a ? b : c ? d : e is a ? b : (c ? d : e)
+func Ternary(scanner TokenScanner) *stmt {
+	e := &stmt{scanner: scanner, name: "Ternary"}
+	e.handle = func(left Expr, token *Token) (matched bool, ret Expr, err error) {
+		if left == nil || token.Type != TokenQuestion {
 			return
 		}
 		matched = true
-		e.scanner.Forward()
-		if !val.Bool() {
+		p := posOf(token)
+		scanner.Forward()
+		// the colon ends the first branch even in an object
+		scanner.PushEnds(TokenColon)
+		then, err := newStmts(scanner, e.opt).Parse(nil)
+		scanner.PopEnds(TokenColon)
+		if err != nil {
 			return
 		}
-		var right Value
-		if right, err = e.next.Value(val, ctx); err != nil {
+		if then == nil {
+			err = newError(ErrParse, p, errors.New("missing the value of [?]"))
 			return
 		}
-		ret = val.Or(right)
-		return
-	}
-	return e
-}
-
-func And(scanner TokenScanner) *stmt {
-	e := &stmt{scanner: scanner, name: "And"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		if token.Type != TokenAnd {
+		if _, err = scanner.Scan(); err != nil {
 			return
 		}
-		matched = true
-		e.scanner.Forward()
-		if !val.Bool() {
+		colon := *scanner.Token()
+		if colon.Type != TokenColon {
+			err = expectError(&colon, TokenColon)
 			return
 		}
-		var right Value
-		if right, err = e.next.Value(val, ctx); err != nil {
+		scanner.Forward()
+		var els Expr
+		if els, err = e.Parse(nil); err != nil {
 			return
 		}
-		ret = val.And(right)
+		if els == nil {
+			err = newError(ErrParse, posOf(&colon), errors.New("missing the value after [:]"))
+			return
+		}
+		ret = &ternaryExpr{cond: left, then: then, els: els}
 		return
 	}
 	return e
 }
 
-func Compare(scanner TokenScanner) *stmt {
-	e := &stmt{scanner: scanner, name: "Compare"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		switch token.Type {
-		case TokenEqual, TokenNotEqual:
-			matched = true
-			scanner.Forward()
-			var right Value
-			right, err = e.next.Value(val, ctx)
-			if err != nil {
-				return
-			}
-			boo := val.Equal(right)
-			if TokenNotEqual == token.Type {
-				boo = !boo
-			}
-			ret = BoolValue(boo)
+// binary a stmt for the left associative binary operators
+func binary(scanner TokenScanner, name string, tts ...TokenType) *stmt {
+	e := &stmt{scanner: scanner, name: name}
+	e.handle = func(left Expr, token *Token) (matched bool, ret Expr, err error) {
+		if left == nil {
 			return
-		case TokenGreateThan, TokenGreateThanEqual, TokenLessThan, TokenLessThanEqual:
-			matched = true
-			scanner.Forward()
-			var right Value
-			right, err = e.next.Value(val, ctx)
-			if err != nil {
-				return
+		}
+		for _, tt := range tts {
+			if token.Type != tt {
+				continue
 			}
-			var com int
-			com, err = val.Compare(right)
-			if err != nil {
+			matched = true
+			p := posOf(token)
+			var right Expr
+			if right, err = e.right(token); err != nil {
 				return
 			}
-			switch token.Type {
-			case TokenGreateThan:
-				ret = BoolValue(com > 0)
-			case TokenGreateThanEqual:
-				ret = BoolValue(com >= 0)
-			case TokenLessThan:
-				ret = BoolValue(com < 0)
-			case TokenLessThanEqual:
-				ret = BoolValue(com <= 0)
-			}
+			ret = &binaryExpr{pos: p, op: tt, left: left, right: right}
 			return
 		}
 		return
@@ -194,213 +192,299 @@ func Compare(scanner TokenScanner) *stmt {
 	return e
 }
 
+// Coalesce a ?? b, b is evaluated only if a is null
+func Coalesce(scanner TokenScanner) *stmt {
+	return binary(scanner, "Coalesce", TokenCoalesce)
+}
+
+func Or(scanner TokenScanner) *stmt {
+	return binary(scanner, "Or", TokenOr)
+}
+
+func And(scanner TokenScanner) *stmt {
+	return binary(scanner, "And", TokenAnd)
+}
+
+func Compare(scanner TokenScanner) *stmt {
+	return binary(scanner, "Compare",
+		TokenEqual, TokenNotEqual,
+		TokenGreateThan, TokenGreateThanEqual, TokenLessThan, TokenLessThanEqual)
+}
+
 func AddOrMinus(scanner TokenScanner) *stmt {
-	e := &stmt{scanner: scanner, name: "AddOrMinus"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		switch token.Type {
-		case TokenAddition:
-			matched = true
-			scanner.Forward()
-			var term Value
-			if term, err = e.next.Value(val, ctx); err != nil {
-				return
-			}
-			ret, err = val.Add(term)
-			return
-		case TokenMinus:
-			matched = true
-			scanner.Forward()
-			var term Value
-			if term, err = e.next.Value(val, ctx); err != nil {
-				return
-			}
-			ret, err = val.Minus(term)
-			return
-		}
-		return
-	}
-	return e
+	return binary(scanner, "AddOrMinus", TokenAddition, TokenMinus)
 }
 
 func MultiplyOrDevide(scanner TokenScanner) *stmt {
-	e := &stmt{scanner: scanner, name: "MultiplyOrDevide"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		switch token.Type {
-		case TokenMultiplication:
-			matched = true
-			scanner.Forward()
-			var term Value
-			if term, err = e.next.Value(val, ctx); err != nil {
-				return
-			}
-			ret, err = val.Multiply(term)
-			return
-		case TokenDevision:
-			matched = true
-			scanner.Forward()
-			var term Value
-			if term, err = e.next.Value(val, ctx); err != nil {
-				return
-			}
-			ret, err = val.Devide(term)
-			return
-		}
-		return
-	}
-	return e
+	return binary(scanner, "MultiplyOrDevide", TokenMultiplication, TokenDevision)
 }
 
 func Mod(scanner TokenScanner) *stmt {
-	e := &stmt{scanner: scanner, name: "Mod"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		if token.Type != TokenMod {
+	return binary(scanner, "Mod", TokenMod)
+}
+
+// Range a ... b excludes b, a ..= b includes b, and the step follows the colon, such as 0 ... 100 : 5
+func Range(scanner TokenScanner) *stmt {
+	e := &stmt{scanner: scanner, name: "Range"}
+	e.handle = func(left Expr, token *Token) (matched bool, ret Expr, err error) {
+		if left == nil || token.Type != TokenRange && token.Type != TokenRangeInclusive {
 			return
 		}
 		matched = true
-		scanner.Forward()
-		var term Value
-		if term, err = e.next.Value(val, ctx); err != nil {
+		rg := &rangeExpr{pos: posOf(token), begin: left, inclusive: token.Type == TokenRangeInclusive}
+		if rg.end, err = e.right(token); err != nil {
 			return
 		}
-		ret, err = val.Mod(term)
-		return
-	}
-	return e
-}
-
-func Call(scanner TokenScanner) *stmt {
-	e := &stmt{scanner: scanner, name: "Call"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		if !(val.Type == ValueIdentifier && e.scanner.Token().Type == TokenParenthesesOpen) {
+		// the colon may end the branch of ?: instead
+		var end bool
+		if end, err = scanner.Scan(); err != nil {
 			return
 		}
-		matched = true
-		scanner.Forward()
-		identifier := val.Value.(Identifier)
-		e.scanner.PushEnds(TokenParenthesesClose)
-		defer e.scanner.PopEnds(TokenParenthesesClose)
-		if ret, err = identifier.Call(e.scanner, ctx); err != nil {
-			return
+		if !end && scanner.Token().Type == TokenColon {
+			if rg.step, err = e.right(scanner.Token()); err != nil {
+				return
+			}
 		}
+		ret = rg
 		return
 	}
 	return e
 }
 
-func Dot(scanner TokenScanner) *stmt {
-	e := &stmt{scanner: scanner, name: "Dot"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		if token.Type != TokenDot {
+// OptionalChain a?.b.c and a?.m(...), the chain after ?. is evaluated only if a isn't null,
+// otherwise the chain is null
+func OptionalChain(scanner TokenScanner) *stmt {
+	e := &stmt{scanner: scanner, name: "OptionalChain"}
+	e.handle = func(left Expr, token *Token) (matched bool, ret Expr, err error) {
+		if left == nil || token.Type != TokenOptionalDot {
 			return
 		}
 		matched = true
 		scanner.Forward()
-		var right Value
-		right, err = e.next.Value(NullValue(), ctx)
-		if err != nil {
+		if _, err = scanner.Scan(); err != nil {
+			return
+		}
+		var member *memberExpr
+		if member, err = parseMember(scanner, &holeExpr{}); err != nil {
 			return
 		}
-		if right.Type != ValueIdentifier {
-			err = errors.New("an identifier must be followed by dot")
+		// the rest of the chain, such as .c and (...) of a?.b.c(...), is built on the member
+		var chain Expr
+		if chain, err = e.next.Parse(member); err != nil {
+			return
 		}
-		right.Value.(Identifier).SetParent(val)
-		ret = right
+		ret = &optionalExpr{left: left, chain: chain}
 		return
 	}
 	return e
 }
 
-func Range(scanner TokenScanner) *stmt {
-	e := &stmt{scanner: scanner, name: "Range"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
-		if token.Type != TokenRange {
+// memberKeywords the keywords which are the names of the members after a dot, such as a.if(...)
+var memberKeywords = map[TokenType]string{
+	TokenIf:     "if",
+	TokenElse:   "else",
+	TokenEmit:   "emit",
+	TokenImport: "import",
+}
+
+// Call the member access and the call on the member, such as a.b.map(...)
+func Call(scanner TokenScanner) *stmt {
+	e := &stmt{scanner: scanner, name: "Call"}
+	e.handle = func(left Expr, token *Token) (matched bool, ret Expr, err error) {
+		if left == nil {
 			return
 		}
-		matched = true
-		scanner.Forward()
-		var begin, end int64
-		if inter, ok := val.Value.(Inter); ok {
-			if begin, err = inter.Int(); err != nil {
-				err = fmt.Errorf("can't convert to int for range begin: %w", err)
+		switch token.Type {
+		case TokenDot:
+			matched = true
+			scanner.Forward()
+			if _, err = scanner.Scan(); err != nil {
 				return
 			}
-		} else {
-			err = errors.New("range ... must follow an int and be followed by an int too")
-			return
-		}
-		var right Value
-		right, err = e.next.Value(val, ctx)
-		if err != nil {
-			return
-		}
-		if inter, ok := right.Value.(Inter); ok {
-			if end, err = inter.Int(); err != nil {
-				err = fmt.Errorf("can't convert to int for range end: %w", err)
+			ret, err = parseMember(scanner, left)
+		case TokenParenthesesOpen:
+			var p pos
+			switch fn := left.(type) {
+			case *identExpr:
+				p = fn.pos
+			case *memberExpr:
+				p = fn.pos
+			default:
 				return
 			}
-		} else {
-			err = errors.New("range ... must follow an int and be followed by an int too")
-			return
+			matched = true
+			scanner.Forward()
+			var args Expr
+			if args, err = parseArgs(scanner, e.opt); err != nil {
+				return
+			}
+			ret = &callExpr{pos: p, fn: left, args: args}
 		}
-		ret = RangeValue(int(begin), int(end))
 		return
 	}
 	return e
 }
 
+// parseFraction parse the number without the integer part after the dot, such as .5,
+// the digits must follow the dot closely
+func parseFraction(scanner TokenScanner, token *Token) (ret Expr, err error) {
+	dot := *token
+	if _, err = scanner.Scan(); err != nil {
+		return
+	}
+	num := scanner.Token()
+	if num.Type != TokenNumber || bytes.IndexByte(num.Raw, '.') > -1 || num.Row != dot.Row || num.Col != dot.Col+1 {
+		err = newError(ErrParse, posOf(&dot), fmt.Errorf("unexpected token [%s]", dot.Name()))
+		return
+	}
+	scanner.Forward()
+	v, _ := strconv.ParseFloat("0."+string(num.Raw), 64)
+	return &literalExpr{val: FloatValue(v)}, nil
+}
+
 func Factor(scanner TokenScanner) *stmt {
 	e := &stmt{scanner: scanner, name: "Factor"}
-	e.handle = func(val Value, ctx Context, token *Token) (matched bool, ret Value, err error) {
+	e.handle = func(_ Expr, token *Token) (matched bool, ret Expr, err error) {
 		scanner.Forward()
 		switch token.Type {
 		case TokenIdentifier:
-			ret = Value{Type: ValueIdentifier, Value: &identifier{
-				name: token.Raw,
-				vars: ctx.Copy(),
-			}}
+			ret = &identExpr{pos: posOf(token), name: token.Raw}
 		case TokenExit:
-			Exit()
+			ret = &exitExpr{}
 		case TokenReturn:
-			ret = ReturnValue()
+			ret = &returnExpr{}
+		case TokenEmit:
+			ret, err = parseEmit(scanner, token, e.opt)
+		case TokenImport:
+			ret, err = parseImport(scanner, token, e.opt)
+		case TokenIf:
+			ret, err = parseIf(scanner, token, e.opt)
+		case TokenNull:
+			ret = &literalExpr{val: NullValue()}
 		case TokenTrue:
-			ret = BoolValue(true)
+			ret = &literalExpr{val: BoolValue(true)}
 		case TokenFalse:
-			ret = BoolValue(false)
+			ret = &literalExpr{val: BoolValue(false)}
 		case TokenString:
-			ret = StringValue(token.Raw...)
+			ret = &literalExpr{val: StringValue(token.Raw...)}
+		case TokenTemplate:
+			ret, err = parseTemplate(token, e.opt)
 		case TokenNumber:
 			if bytes.Contains(token.Raw, []byte{'.'}) {
 				v, _ := strconv.ParseFloat(string(token.Raw), 64)
-				ret = FloatValue(v)
+				ret = &literalExpr{val: FloatValue(v)}
 				return
 			}
 			v, _ := strconv.ParseInt(string(token.Raw), 10, 64)
-			ret = IntValue(v)
+			ret = &literalExpr{val: IntValue(v)}
+		case TokenDot:
+			ret, err = parseFraction(scanner, token)
 		case TokenParenthesesOpen:
-			e.scanner.PushEnds(TokenParenthesesClose)
-			defer e.scanner.PopEnds(TokenParenthesesClose)
-			sub := NewStmtExecutor(e.scanner, ctx)
-			if err = sub.Execute(); err == nil {
-				ret = sub.value
-			}
+			ret, err = parseArgs(scanner, e.opt)
 		case TokenBracketsOpen:
-			sub := newArrayExecutor(e.scanner, ctx)
-			if err = sub.execute(); err == nil {
-				ret = sub.value
-			}
+			ret, err = parseArray(scanner, e.opt)
 		case TokenBraceOpen:
-			sub := newObjectExecutor(e.scanner, ctx)
-			if err = sub.execute(); err == nil {
-				ret = sub.value
-			}
+			ret, err = parseObject(scanner, e.opt)
 		default:
-			err = fmt.Errorf("unexpected token [%s] at %d, %d", token.Name(), token.Row, token.Col)
+			err = newError(ErrParse, posOf(token), fmt.Errorf("unexpected token [%s]", token.Name()))
 		}
 		return
 	}
 	return e
 }
 
+// parseEmit parse the value after emit, such as emit {"name": name}
+func parseEmit(scanner TokenScanner, token *Token, opt *option) (ret Expr, err error) {
+	p := posOf(token)
+	var val Expr
+	if val, err = newStmts(scanner, opt).Parse(nil); err != nil {
+		return
+	}
+	if val == nil {
+		err = newError(ErrParse, p, errors.New("missing the value to emit"))
+		return
+	}
+	ret = &emitExpr{pos: p, val: val}
+	return
+}
+
+// parseIf parse the branches after if, such as if a { ... } else if b { ... } else { ... }
+func parseIf(scanner TokenScanner, token *Token, opt *option) (ret Expr, err error) {
+	e := &ifExpr{}
+	for {
+		p := posOf(token)
+		var cond Expr
+		if cond, err = newStmts(scanner, opt).Parse(nil); err != nil {
+			return
+		}
+		if cond == nil {
+			err = newError(ErrParse, p, errors.New("missing the condition of [if]"))
+			return
+		}
+		var body *blockExpr
+		if body, err = parseBraces(scanner, opt); err != nil {
+			return
+		}
+		e.branches = append(e.branches, ifBranch{cond: cond, body: body})
+		if _, err = scanner.Scan(); err != nil {
+			return
+		}
+		if scanner.Token().Type != TokenElse {
+			break
+		}
+		scanner.Forward()
+		if _, err = scanner.Scan(); err != nil {
+			return
+		}
+		if token = scanner.Token(); token.Type != TokenIf {
+			e.els, err = parseBraces(scanner, opt)
+			break
+		}
+		scanner.Forward()
+	}
+	ret = e
+	return
+}
+
+// parseBraces parse the stmts in the braces of a statement block, such as the body of if
+func parseBraces(scanner TokenScanner, opt *option) (block *blockExpr, err error) {
+	if _, err = scanner.Scan(); err != nil {
+		return
+	}
+	if token := scanner.Token(); token.Type != TokenBraceOpen {
+		err = expectError(token, TokenBraceOpen)
+		return
+	}
+	scanner.Forward()
+	scanner.PushEnds(TokenBraceClose)
+	block, err = parseBlock(scanner, opt)
+	scanner.PopEnds(TokenBraceClose)
+	if err != nil {
+		return
+	}
+	if token := scanner.Token(); token.Type != TokenBraceClose {
+		err = expectError(token, TokenBraceClose)
+		return
+	}
+	scanner.Forward()
+	return
+}
+
+// parseMember parse the name after the dot as a member of left
+func parseMember(scanner TokenScanner, left Expr) (ret *memberExpr, err error) {
+	name := scanner.Token()
+	raw := name.Raw
+	if kw, ok := memberKeywords[name.Type]; ok {
+		raw = []byte(kw)
+	} else if name.Type != TokenIdentifier && name.Type != TokenNumber {
+		err = newError(ErrParse, posOf(name), fmt.Errorf("unexpected token [%s], dot must be followed by an identifier", name.Name()))
+		return
+	}
+	scanner.Forward()
+	ret = &memberExpr{pos: posOf(name), left: left, name: raw}
+	return
+}
+
 type stmts []*stmt
 
 func (es stmts) init(opt *option) *stmt {
@@ -413,9 +497,91 @@ func (es stmts) init(opt *option) *stmt {
 	return es[0]
 }
 
+func newStmts(scanner TokenScanner, opt *option) *stmt {
+	return stmts([]*stmt{
+		Assign(scanner),
+		Reduction(scanner),
+		Ternary(scanner),
+		Coalesce(scanner),
+		Or(scanner),
+		And(scanner),
+		Compare(scanner),
+		Range(scanner),
+		AddOrMinus(scanner),
+		MultiplyOrDevide(scanner),
+		Mod(scanner),
+		OptionalChain(scanner),
+		Call(scanner),
+		Factor(scanner),
+	}).init(opt)
+}
+
+// parseBlock parse stmts separated by semicolon till the scanner ends
+func parseBlock(scanner TokenScanner, opt *option) (block *blockExpr, err error) {
+	expr := newStmts(scanner, opt)
+	block = &blockExpr{}
+	var end bool
+	var s Expr
+	for {
+		if end, err = scanner.Scan(); err == nil && end {
+			return
+		}
+		if err == nil && scanner.Token().Type == TokenSemicolon {
+			scanner.Forward()
+			continue
+		}
+		if err == nil {
+			if s, err = expr.Parse(nil); err == nil {
+				block.stmts = append(block.stmts, s)
+				continue
+			}
+		}
+		if err = opt.recoverFrom(scanner, err); err != nil {
+			return
+		}
+	}
+}
+
+func expectError(token *Token, expect TokenType) error {
+	return newError(ErrParse, posOf(token), fmt.Errorf("unexpected token [%s], expect [%s]", token.Name(), (&Token{Type: expect}).Name()))
+}
+
+// parseArgs parse the args separated by comma till the close parentheses, a single arg
+// is returned as the block itself, and the args more than one as an argsExpr
+func parseArgs(scanner TokenScanner, opt *option) (ret Expr, err error) {
+	scanner.PushEnds(TokenComma, TokenParenthesesClose)
+	defer scanner.PopEnds(TokenComma, TokenParenthesesClose)
+	args := &argsExpr{}
+	var block *blockExpr
+	for {
+		if block, err = parseBlock(scanner, opt); err != nil {
+			return
+		}
+		args.items = append(args.items, block)
+		token := scanner.Token()
+		if token.Type == TokenComma {
+			scanner.Forward()
+			continue
+		}
+		if token.Type != TokenParenthesesClose {
+			err = expectError(token, TokenParenthesesClose)
+			return
+		}
+		scanner.Forward()
+		break
+	}
+	// skip the trailing comma
+	if n := len(args.items); n > 1 && len(args.items[n-1].(*blockExpr).stmts) == 0 {
+		args.items = args.items[:n-1]
+	}
+	if len(args.items) == 1 {
+		return args.items[0], nil
+	}
+	return args, nil
+}
+
 type stmtExecutor struct {
 	scanner TokenScanner
-	expr    *stmt
 	value   Value
 	ctx     Context
 	opt     *option
@@ -423,7 +589,50 @@ type stmtExecutor struct {
 }
 
 type option struct {
-	debug bool
+	debug       bool
+	file        string
+	recovery    bool     // go on parsing after an error
+	diagnostics []*Error // errors found in the recovery mode
+	limits      *Limits  // limits of the evaluation
+	loader      Loader   // loader of the imported files
+}
+
+// recoverFrom record the err and skip the tokens till the next semicolon, comma or closing bracket,
+// so the parsing can go on after the err. if not in the recovery mode, the err is returned back
+func (opt *option) recoverFrom(scanner TokenScanner, err error) error {
+	var e *Error
+	if !opt.recovery || !errors.As(err, &e) {
+		return err
+	}
+	opt.diagnostics = append(opt.diagnostics, e)
+	for {
+		end, err := scanner.Scan()
+		if err != nil {
+			if !errors.As(err, &e) {
+				return err
+			}
+			opt.diagnostics = append(opt.diagnostics, e)
+			scanner.Forward()
+			continue
+		}
+		switch scanner.Token().Type {
+		case TokenEOF:
+			return nil
+		case TokenSemicolon:
+			scanner.Forward()
+			return nil
+		case TokenComma, TokenParenthesesClose, TokenBracketsClose, TokenBraceClose:
+			// leave the closing token to the enclosing parser if it ends the current block
+			if !end {
+				scanner.Forward()
+			}
+			return nil
+		}
+		if end {
+			return nil
+		}
+		scanner.Forward()
+	}
 }
 
 type StmtOption func(opt *option)
@@ -434,27 +643,20 @@ func Debug() StmtOption {
 	}
 }
 
+// Filename set the name of the source file, the name is used to locate the errors
+func Filename(name string) StmtOption {
+	return func(opt *option) {
+		opt.file = name
+	}
+}
+
 func NewStmtExecutor(scanner TokenScanner, ctx Context, opts ...StmtOption) *stmtExecutor {
-	es := stmts([]*stmt{
-		Assign(scanner),
-		Reduction(scanner),
-		Or(scanner),
-		And(scanner),
-		Compare(scanner),
-		AddOrMinus(scanner),
-		MultiplyOrDevide(scanner),
-		Mod(scanner),
-		Call(scanner),
-		Dot(scanner),
-		Range(scanner),
-		Factor(scanner),
-	})
 	opt := &option{}
 	for _, apply := range opts {
 		apply(opt)
 	}
 	return &stmtExecutor{
-		expr: es.init(opt), scanner: scanner, opt: opt,
+		scanner: scanner, opt: opt,
 		ctx: ctx,
 	}
 }
@@ -478,24 +680,18 @@ func EndWhen(tt ...TokenType) func(opt *stmtExecOption) {
 	}
 }
 
+// Execute parse the stmts till the scanner ends and evaluate them
 func (ns *stmtExecutor) Execute(applyOpt ...func(opt *stmtExecOption)) (err error) {
+	return ns.ExecuteContext(context.Background(), applyOpt...)
+}
+
+// ExecuteContext execute like Execute, the evaluation stops with the error of goctx once goctx is done
+func (ns *stmtExecutor) ExecuteContext(goctx context.Context, applyOpt ...func(opt *stmtExecOption)) (err error) {
 	defer func() {
 		if ns.scanner.Token().Type != TokenEOF {
 			ns.scanner.Forward()
 		}
 	}()
-	defer func() {
-		if r := recover(); r != nil {
-			if err, ok := r.(error); ok && errors.Is(err, errExit) {
-				ns.exited = true
-				return
-			}
-			panic(r)
-		}
-	}()
-	var end bool
-	var returned bool
-	var val Value
 	if ns.ctx == nil {
 		ns.ctx = NewContext()
 	}
@@ -506,33 +702,25 @@ func (ns *stmtExecutor) Execute(applyOpt ...func(opt *stmtExecOption)) (err erro
 	if opt.valSetted {
 		ns.value = opt.val
 	}
-	if len(opt.endWhen) > 0 {
-		ns.scanner.PushEnds(opt.endWhen...)
-		defer ns.scanner.PopEnds(opt.endWhen...)
-	}
-	ns.ctx.PushScope()
-	defer ns.ctx.PopScope()
-	for {
-		if end, err = ns.scanner.Scan(); end || err != nil {
-			return
-		}
-		if ns.scanner.Token().Type == TokenSemicolon {
-			ns.scanner.Forward()
-			continue
-		}
-		if returned {
-			// drop all the rest token
-			continue
-		}
-		if val, err = ns.expr.Value(val, ns.ctx); err != nil {
-			return
-		}
-		if val.Type == ValueReturn {
-			returned = true
-			continue
+	var block *blockExpr
+	func() {
+		if len(opt.endWhen) > 0 {
+			ns.scanner.PushEnds(opt.endWhen...)
+			defer ns.scanner.PopEnds(opt.endWhen...)
 		}
-		ns.value = val
+		block, err = parseBlock(ns.scanner, ns.opt)
+	}()
+	if err != nil {
+		return
+	}
+	prog := &Program{block: block, limits: ns.opt.limits}
+	var val Value
+	val, ns.exited, err = prog.eval(goctx, ns.ctx, nil)
+	if err != nil || len(block.stmts) == 0 {
+		return
 	}
+	ns.value = val
+	return
 }
 
 func (ns *stmtExecutor) AssignVar(name []byte, val Value) {
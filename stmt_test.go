@@ -269,6 +269,111 @@ users = [1 ... 10000].map({
 	t.Log(stmt.Value())
 }
 
+func TestStmt_if(t *testing.T) {
+	runCases(t, []stmtCase{
+		{src: `env = "prod"; if env == "prod" { replicas = 3; replicas * 2 } else { 1 }`, val: "6"},
+		{src: `env = "dev"; if env == "prod" { 3 } else if env == "dev" { 2 } else { 1 }`, val: "2"},
+		{src: `env = "test"; if env == "prod" { 3 } else if env == "dev" { 2 } else { 1 }`, val: "1"},
+		{src: `if false { 3 }`, val: "null"},
+		// the variables declared in the block don't leak
+		{src: `a = 1; if true { b = 2; a = a + b }; [a, b]`, val: "[3,null]"},
+		// return in the block returns from the enclosing block
+		{src: `[1 ... 4].map(if v == 2 { return }; v)`, val: "[1,null,3]"},
+		{src: `{"a": 1}.if(_me.a == 1 => {"a": 2}).a`, val: "2"},
+	})
+}
+
+func TestStmt_ternary(t *testing.T) {
+	runCases(t, []stmtCase{
+		{src: `n = 5; n > 3 ? "big" : "small"`, val: `"big"`},
+		{src: `n = 1; size = n > 3 ? "big" : "small"; size`, val: `"small"`},
+		{src: `n = 1; n > 3 ? "big" : n > 0 ? "positive" : "other"`, val: `"positive"`},
+		{src: `n = 1; {"a": n == 1 ? {"b": 2} : 3, "c": true ? 4 : 5}.a.b`, val: "2"},
+		{src: `[1 ... 4].map(v % 2 == 0 ? "even" : "odd")`, val: `["odd","even","odd"]`},
+		{src: `true ? 1`, shoulderr: true},
+		{src: `true ? : 2`, shoulderr: true},
+		{src: `true ? 1 :`, shoulderr: true},
+		{src: `if true 1`, shoulderr: true},
+		{src: `if { 1 }`, shoulderr: true},
+	})
+}
+
+func TestStmt_coalesce(t *testing.T) {
+	runCases(t, []stmtCase{
+		{src: `port ?? 8080`, val: "8080"},
+		{src: `port = 80; port ?? 8080`, val: "80"},
+		{src: `conf = {"port": null}; conf.port ?? conf.default ?? 8080`, val: "8080"},
+		{src: `conf = {"debug": false}; conf.debug ?? true`, val: "false"},
+		{src: `name = null; "user-" + (name ?? "guest")`, val: `"user-guest"`},
+		// the right side is evaluated only if the left is null
+		{src: `n = 1; 1 ?? (n = 2); n`, val: "1"},
+		{src: `a = null; a ?? false || true`, val: "true"},
+	})
+}
+
+func TestStmt_optionalChain(t *testing.T) {
+	runCases(t, []stmtCase{
+		{src: `conf = {"db": {"host": "h", "ports": [1, 2]}}; conf?.db?.host`, val: `"h"`},
+		{src: `conf = {"db": {"host": "h", "ports": [1, 2]}}; conf?.db.ports.1`, val: "2"},
+		{src: `conf = {}; conf?.db?.host`, val: "null"},
+		// the rest of the chain is skipped once null
+		{src: `c = "host"; conf = null; conf?.db.c`, val: "null"},
+		{src: `missing?.name`, val: "null"},
+		{src: `s = null; s?.index("a")`, val: "null"},
+		{src: `users = [{"name": "a"}, {"name": "b"}]; users?.filter(v.name == "b")?.first()?.name`, val: `"b"`},
+		{src: `conf = {"tags": null}; conf?.tags?.filter(v == 1) ?? []`, val: "[]"},
+		{src: `f = (o) -> o?.name ?? "anonymous"; [f({"name": "x"}), f(null)]`, val: `["x","anonymous"]`},
+		{src: `n = 1; n > 0 ? {"a": 1}?.a : 0`, val: "1"},
+		{src: `n = 1; n > 0 ?.5 : 2`, val: "0.5"},
+		{src: `n = 0; n > 0 ?.5 : 2`, val: "2"},
+		{src: `[.5, [0, 1].1, true ? .25 : 0]`, val: "[0.5,1,0.25]"},
+		{src: `x = . 5`, shoulderr: true},
+		{src: `a?.`, shoulderr: true},
+		{src: `a?.(1)`, shoulderr: true},
+		{src: `a ??`, shoulderr: true},
+	})
+}
+
+func jsonString(val Value) string {
+	var b bytes.Buffer
+	NewJsonEncoder().Encode(val, &b)
+	return b.String()
+}
+
+// stmtCase a djson source and the json of its value, or whether it should fail
+type stmtCase struct {
+	src       string
+	val       string
+	shoulderr bool
+}
+
+// evalSource execute the source within a new Context
+func evalSource(src string) (Value, error) {
+	stmt := NewStmtExecutor(NewTokenScanner(NewLexer(strings.NewReader(src), 128)), NewContext())
+	err := stmt.Execute()
+	return stmt.Value(), err
+}
+
+// runCases evaluate each source, and compare the json of the value with val
+func runCases(t *testing.T, cases []stmtCase) {
+	t.Helper()
+	for i, item := range cases {
+		val, err := evalSource(item.src)
+		if item.shoulderr {
+			if err == nil {
+				t.Fatalf("%d: %s should fail", i, item.src)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if got := jsonString(val); got != item.val {
+			t.Fatalf("%d: expect %s, got %s", i, item.val, got)
+		}
+	}
+}
+
 func BenchmarkStmt_config(b *testing.B) {
 	data := `
 # a config test
@@ -321,6 +426,7 @@ func BenchmarkStmt_arithmatic(b *testing.B) {
 			{Type: TokenNumber, Raw: []byte{'3'}},
 			{Type: TokenEqual},
 			{Type: TokenNumber, Raw: []byte{'2', '1'}},
+			{Type: TokenParenthesesClose},
 			{Type: TokenOr},
 			{Type: TokenTrue},
 			{Type: TokenReduction},
@@ -4,8 +4,15 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 )
 
 type Stringer interface {
@@ -37,6 +44,37 @@ func NewString(bs ...byte) *str {
 	s.RegisterCall("index", indexString)
 	s.RegisterCall("match", matchString)
 	s.RegisterCall("sub", subString)
+	s.RegisterCall("upper", upperString)
+	s.RegisterCall("lower", lowerString)
+	s.RegisterCall("title", titleString)
+	s.RegisterCall("trim", trimString("trim", strings.Trim, strings.TrimSpace))
+	s.RegisterCall("trimLeft", trimString("trimLeft", strings.TrimLeft, func(s string) string {
+		return strings.TrimLeftFunc(s, unicode.IsSpace)
+	}))
+	s.RegisterCall("trimRight", trimString("trimRight", strings.TrimRight, func(s string) string {
+		return strings.TrimRightFunc(s, unicode.IsSpace)
+	}))
+	s.RegisterCall("split", splitString)
+	s.RegisterCall("replace", replaceString)
+	s.RegisterCall("startsWith", hasString("startsWith", strings.HasPrefix))
+	s.RegisterCall("endsWith", hasString("endsWith", strings.HasSuffix))
+	s.RegisterCall("contains", hasString("contains", strings.Contains))
+	s.RegisterCall("repeat", repeatString)
+	s.RegisterCall("padLeft", padString("padLeft", true))
+	s.RegisterCall("padRight", padString("padRight", false))
+	s.RegisterCall("len", lenString)
+	s.RegisterCall("format", formatString)
+	s.RegisterCall("reverse", reverseString)
+	s.RegisterCall("chars", charsString)
+	s.RegisterCall("find", findString)
+	s.RegisterCall("findAll", findAllString)
+	s.RegisterCall("replaceAll", replaceAllString)
+	s.RegisterCall("byteIndex", byteIndexString)
+	s.RegisterCall("byteSub", byteSubString)
+	s.RegisterCall("byteLen", byteLenString)
+	s.RegisterCall("normalize", normalizeString)
+	s.RegisterCall("fold", foldString)
+	s.RegisterCall("equalFold", equalFoldString)
 	return s
 }
 
@@ -116,6 +154,7 @@ func (s *str) Or(val Value) (ret Value, err error) {
 	return
 }
 
+// Compare compare the strings by the code points of the chars, the bytes of utf-8 are in the same order
 func (s *str) Compare(val Value) (ret int, err error) {
 	if val.Type != ValueString {
 		err = fmt.Errorf("can't compare string with [%s]", val.TypeName())
@@ -133,89 +172,519 @@ func (s *str) Concat(ss []byte) {
 	s.bytes = append(s.bytes, ss...)
 }
 
-func indexString(val Value, scanner TokenScanner, vars Context) (ret Value, err error) {
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
-	stmt := NewStmtExecutor(scanner, vars)
-	if err = stmt.Execute(); err != nil {
+func indexString(val Value, args Expr, vars Context) (ret Value, err error) {
+	var i int
+	if i, err = indexOf("index", val, args, vars); err != nil {
 		return
 	}
-	if stmt.Exited() {
-		Exit()
+	// the offset is counted in runes like sub
+	if i > 0 {
+		i = utf8.RuneCount(val.Value.(String).Bytes()[:i])
 	}
-	if stmt.value.Type != ValueString {
-		err = errors.New("string match only accept a string as the regexp")
+	ret = Value{Type: ValueInt, Value: Int(int64(i))}
+	return
+}
+
+// byteIndexString index by the byte offset
+func byteIndexString(val Value, args Expr, vars Context) (ret Value, err error) {
+	var i int
+	if i, err = indexOf("byteIndex", val, args, vars); err != nil {
 		return
 	}
-	sub := stmt.value.Value.(String).Bytes()
-	s := val.Value.(String).Bytes()
-	ret = Value{Type: ValueInt, Value: Int(int64(bytes.Index(s, sub)))}
+	ret = Value{Type: ValueInt, Value: Int(int64(i))}
 	return
 }
 
-func subString(val Value, scanner TokenScanner, vars Context) (ret Value, err error) {
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
-	stmt := NewStmtExecutor(scanner, vars)
-	if err = stmt.Execute(); err != nil {
+// indexOf the byte offset of the string evaluated from args, -1 if not found
+func indexOf(method string, val Value, args Expr, vars Context) (i int, err error) {
+	var arg Value
+	if arg, err = args.Eval(vars); err != nil {
 		return
 	}
-	if stmt.Exited() {
-		Exit()
+	arg = arg.RealValue()
+	if arg.Type != ValueString {
+		err = fmt.Errorf("string %s only accept a string as the sub string", method)
+		return
 	}
-	if !(stmt.value.Type == ValueArray && stmt.value.Value.(Array).Total() == 2) {
-		err = errors.New("string sub only accept a [start, end] as the range")
+	return bytes.Index(val.Value.(String).Bytes(), arg.Value.(String).Bytes()), nil
+}
+
+func subString(val Value, args Expr, vars Context) (ret Value, err error) {
+	// the offsets are counted in runes, so the multibyte chars aren't broken
+	s := []rune(val.Value.(String).String())
+	var start, end int
+	if start, end, err = subRange("sub", args, vars, len(s), "chars"); err != nil {
 		return
 	}
+	ret = StringValue([]byte(string(s[start:end]))...)
+	return
+}
+
+// byteSubString sub by the byte offsets
+func byteSubString(val Value, args Expr, vars Context) (ret Value, err error) {
 	s := val.Value.(String).Bytes()
-	arr := stmt.value.Value.(Array)
+	var start, end int
+	if start, end, err = subRange("byteSub", args, vars, len(s), "bytes"); err != nil {
+		return
+	}
+	ret = StringValue(append([]byte{}, s[start:end]...)...)
+	return
+}
+
+// subRange evaluate the [start, end] of sub, end is included, null for the begin or the end of the string
+func subRange(method string, args Expr, vars Context, length int, unit string) (start, end int, err error) {
+	var arg Value
+	if arg, err = args.Eval(vars); err != nil {
+		return
+	}
+	arg = arg.RealValue()
+	if !(arg.Type == ValueArray && arg.Value.(Array).Total() == 2) {
+		err = fmt.Errorf("string %s only accept a [start, end] as the range", method)
+		return
+	}
+	arr := arg.Value.(Array)
 	a1 := arr.Get(0)
-	var start int
 	if a1.Type == ValueInt {
 		v, _ := a1.Value.(Inter).Int()
 		start = int(v)
 	} else if a1.Type == ValueNull {
 		start = 0
 	} else {
-		err = errors.New("string sub only accept a [start, end] as the range")
+		err = fmt.Errorf("string %s only accept a [start, end] as the range", method)
 		return
 	}
 	a2 := arr.Get(1)
-	var end int
 	if a2.Type == ValueInt {
 		v, _ := a2.Value.(Inter).Int()
 		end = int(v) + 1
 	} else if a2.Type == ValueNull {
-		end = len(s)
+		end = length
 	} else {
-		err = errors.New("string sub only accept a [start, end] as the range")
+		err = fmt.Errorf("string %s only accept a [start, end] as the range", method)
 		return
 	}
-	if end > len(s) {
-		end = len(s)
+	if end > length {
+		end = length
+	}
+	if start < 0 || start > end {
+		err = fmt.Errorf("string %s out of range [%d, %d] for the string of %d %s", method, start, end-1, length, unit)
 	}
-	ret = Value{Type: ValueString, Value: NewString(s[start:end]...)}
 	return
 }
 
-func matchString(val Value, scanner TokenScanner, vars Context) (ret Value, err error) {
-	scanner.PushEnds(TokenParenthesesClose)
-	defer scanner.PopEnds(TokenParenthesesClose)
-	stmt := NewStmtExecutor(scanner, vars)
-	if err = stmt.Execute(); err != nil {
+func matchString(val Value, args Expr, vars Context) (ret Value, err error) {
+	var arg Value
+	if arg, err = args.Eval(vars); err != nil {
 		return
 	}
-	if stmt.Exited() {
-		Exit()
-	}
-	if stmt.value.Type != ValueString {
+	arg = arg.RealValue()
+	if arg.Type != ValueString {
 		err = errors.New("string match only accept a string as the regexp")
 		return
 	}
 	var reg *regexp.Regexp
-	if reg, err = regexp.Compile(string(stmt.value.Value.(String).Bytes())); err != nil {
+	if reg, err = regexp.Compile(string(arg.Value.(String).Bytes())); err != nil {
 		return
 	}
 	ret = Value{Type: ValueBool, Value: reg.Match(val.Value.(String).Bytes())}
 	return
 }
+
+// stringArgs evaluate the args of the string method one by one, the count of the args must be
+// in [least, most]
+func stringArgs(method string, args Expr, ctx Context, least, most int) (vals []Value, err error) {
+	var items []Expr
+	if items, err = methodArgs("string", method, args, least, most); err != nil {
+		return
+	}
+	vals = make([]Value, len(items))
+	for i, item := range items {
+		if vals[i], err = item.Eval(ctx); err != nil {
+			return
+		}
+		vals[i] = vals[i].RealValue()
+	}
+	return
+}
+
+func stringArg(method string, vals []Value, i int) (string, error) {
+	if vals[i].Type != ValueString {
+		return "", fmt.Errorf("string %s expect a string as the arg %d, got [%s]", method, i+1, vals[i].TypeName())
+	}
+	return vals[i].String(), nil
+}
+
+func intArg(method string, vals []Value, i int) (int, error) {
+	if vals[i].Type != ValueInt {
+		return 0, fmt.Errorf("string %s expect an int as the arg %d, got [%s]", method, i+1, vals[i].TypeName())
+	}
+	n, err := vals[i].Int()
+	return int(n), err
+}
+
+func upperString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = stringArgs("upper", args, ctx, 0, 0); err != nil {
+		return
+	}
+	return StringValue([]byte(strings.ToUpper(val.String()))...), nil
+}
+
+func lowerString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = stringArgs("lower", args, ctx, 0, 0); err != nil {
+		return
+	}
+	return StringValue([]byte(strings.ToLower(val.String()))...), nil
+}
+
+// titleString upper the first letter of each word
+func titleString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = stringArgs("title", args, ctx, 0, 0); err != nil {
+		return
+	}
+	rs := []rune(val.String())
+	inWord := false
+	for i, r := range rs {
+		letter := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+		if letter && !inWord {
+			rs[i] = unicode.ToTitle(r)
+		}
+		inWord = letter
+	}
+	return StringValue([]byte(string(rs))...), nil
+}
+
+// trimString trim the chars of the cutset if given, otherwise the whitespaces
+func trimString(method string, cut func(s, cutset string) string, space func(s string) string) Callback {
+	return func(val Value, args Expr, ctx Context) (ret Value, err error) {
+		var vals []Value
+		if vals, err = stringArgs(method, args, ctx, 0, 1); err != nil {
+			return
+		}
+		if len(vals) == 0 {
+			return StringValue([]byte(space(val.String()))...), nil
+		}
+		var cutset string
+		if cutset, err = stringArg(method, vals, 0); err != nil {
+			return
+		}
+		return StringValue([]byte(cut(val.String(), cutset))...), nil
+	}
+}
+
+// splitString split(sep) or split(sep, n), the string is split into chars if sep is empty,
+// and into n parts at most if n > 0
+func splitString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	var vals []Value
+	if vals, err = stringArgs("split", args, ctx, 1, 2); err != nil {
+		return
+	}
+	var sep string
+	if sep, err = stringArg("split", vals, 0); err != nil {
+		return
+	}
+	n := -1
+	if len(vals) == 2 {
+		if n, err = intArg("split", vals, 1); err != nil {
+			return
+		}
+	}
+	return stringsValue(strings.SplitN(val.String(), sep, n)), nil
+}
+
+// replaceString replace(old, new) or replace(old, new, n), only the first n are replaced if n >= 0
+func replaceString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	var vals []Value
+	if vals, err = stringArgs("replace", args, ctx, 2, 3); err != nil {
+		return
+	}
+	var old, repl string
+	if old, err = stringArg("replace", vals, 0); err != nil {
+		return
+	}
+	if repl, err = stringArg("replace", vals, 1); err != nil {
+		return
+	}
+	n := -1
+	if len(vals) == 3 {
+		if n, err = intArg("replace", vals, 2); err != nil {
+			return
+		}
+	}
+	return StringValue([]byte(strings.Replace(val.String(), old, repl, n))...), nil
+}
+
+func hasString(method string, has func(s, sub string) bool) Callback {
+	return func(val Value, args Expr, ctx Context) (ret Value, err error) {
+		var vals []Value
+		if vals, err = stringArgs(method, args, ctx, 1, 1); err != nil {
+			return
+		}
+		var sub string
+		if sub, err = stringArg(method, vals, 0); err != nil {
+			return
+		}
+		return BoolValue(has(val.String(), sub)), nil
+	}
+}
+
+func repeatString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	var vals []Value
+	if vals, err = stringArgs("repeat", args, ctx, 1, 1); err != nil {
+		return
+	}
+	var n int
+	if n, err = intArg("repeat", vals, 0); err != nil {
+		return
+	}
+	if n < 0 {
+		err = fmt.Errorf("string repeat expect a count >= 0, got %d", n)
+		return
+	}
+	s := val.String()
+	if err = checkGrown(ctx, "repeat", 0, len(s), n); err != nil {
+		return
+	}
+	return StringValue([]byte(strings.Repeat(s, n))...), nil
+}
+
+// maxStringSize the bytes of the longest string built by the methods such as repeat
+const maxStringSize = math.MaxInt32
+
+// checkGrown check the size of base bytes followed by n times of unit bytes, it can't
+// exceed maxStringSize nor the output size of the Limits, a string longer than the output
+// can't be written anyway
+func checkGrown(ctx Context, method string, base, unit, n int) error {
+	if base > maxStringSize || unit > 0 && n > (maxStringSize-base)/unit {
+		return fmt.Errorf("string %s output exceeds %d bytes", method, maxStringSize)
+	}
+	return ctx.evalState().limiter.checkSize(base + unit*n)
+}
+
+// padString padLeft(width) or padLeft(width, pad), the string is padded to width chars with
+// the pad, which is a space by default
+func padString(method string, left bool) Callback {
+	return func(val Value, args Expr, ctx Context) (ret Value, err error) {
+		var vals []Value
+		if vals, err = stringArgs(method, args, ctx, 1, 2); err != nil {
+			return
+		}
+		var width int
+		if width, err = intArg(method, vals, 0); err != nil {
+			return
+		}
+		pad := " "
+		if len(vals) == 2 {
+			if pad, err = stringArg(method, vals, 1); err != nil {
+				return
+			}
+		}
+		s := val.String()
+		short := width - utf8.RuneCountInString(s)
+		if short <= 0 || pad == "" {
+			return val, nil
+		}
+		// the pad repeated, and the leading chars of the pad for the rest
+		chars := []rune(pad)
+		rest := string(chars[:short%len(chars)])
+		if err = checkGrown(ctx, method, len(s)+len(rest), len(pad), short/len(chars)); err != nil {
+			return
+		}
+		padding := strings.Repeat(pad, short/len(chars)) + rest
+		if left {
+			s = padding + s
+		} else {
+			s += padding
+		}
+		return StringValue([]byte(s)...), nil
+	}
+}
+
+// lenString the count of the chars
+func lenString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = stringArgs("len", args, ctx, 0, 0); err != nil {
+		return
+	}
+	return IntValue(int64(utf8.RuneCount(val.Bytes()))), nil
+}
+
+// byteLenString the count of the bytes
+func byteLenString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = stringArgs("byteLen", args, ctx, 0, 0); err != nil {
+		return
+	}
+	return IntValue(int64(len(val.Bytes()))), nil
+}
+
+var normForms = map[string]norm.Form{
+	"NFC":  norm.NFC,
+	"NFD":  norm.NFD,
+	"NFKC": norm.NFKC,
+	"NFKD": norm.NFKD,
+}
+
+// normalizeString normalize() or normalize(form), the form is one of NFC, NFD, NFKC and NFKD, NFC by default
+func normalizeString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	var vals []Value
+	if vals, err = stringArgs("normalize", args, ctx, 0, 1); err != nil {
+		return
+	}
+	name := "NFC"
+	if len(vals) == 1 {
+		if name, err = stringArg("normalize", vals, 0); err != nil {
+			return
+		}
+	}
+	form, ok := normForms[strings.ToUpper(name)]
+	if !ok {
+		err = fmt.Errorf("string normalize expect a form of NFC, NFD, NFKC or NFKD, got [%s]", name)
+		return
+	}
+	return StringValue(form.Bytes(val.Bytes())...), nil
+}
+
+// foldString the case folded string, the strings equal ignoring the cases are the same after folded
+func foldString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = stringArgs("fold", args, ctx, 0, 0); err != nil {
+		return
+	}
+	return StringValue([]byte(cases.Fold().String(val.String()))...), nil
+}
+
+// equalFoldString whether the strings are equal ignoring the cases and the normalization forms
+func equalFoldString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	var vals []Value
+	if vals, err = stringArgs("equalFold", args, ctx, 1, 1); err != nil {
+		return
+	}
+	var other string
+	if other, err = stringArg("equalFold", vals, 0); err != nil {
+		return
+	}
+	fold := func(s string) string {
+		return cases.Fold().String(norm.NFC.String(s))
+	}
+	return BoolValue(fold(val.String()) == fold(other)), nil
+}
+
+// formatString format the args with the string like printf, such as "%s: %.2f".format(name, price)
+func formatString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	var vals []Value
+	if vals, err = stringArgs("format", args, ctx, 0, len(Args(args))); err != nil {
+		return
+	}
+	fargs := make([]interface{}, len(vals))
+	for i, v := range vals {
+		switch v.Type {
+		case ValueInt:
+			fargs[i], _ = v.Int()
+		case ValueFloat:
+			fargs[i], _ = v.Float()
+		case ValueBool:
+			fargs[i] = v.Bool()
+		default:
+			fargs[i] = templateString(v)
+		}
+	}
+	return StringValue([]byte(fmt.Sprintf(val.String(), fargs...))...), nil
+}
+
+func reverseString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = stringArgs("reverse", args, ctx, 0, 0); err != nil {
+		return
+	}
+	rs := []rune(val.String())
+	for i, j := 0, len(rs)-1; i < j; i, j = i+1, j-1 {
+		rs[i], rs[j] = rs[j], rs[i]
+	}
+	return StringValue([]byte(string(rs))...), nil
+}
+
+// charsString split the string into chars
+func charsString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	if _, err = stringArgs("chars", args, ctx, 0, 0); err != nil {
+		return
+	}
+	return stringsValue(strings.Split(val.String(), "")), nil
+}
+
+func regexpArg(method string, vals []Value, i int) (reg *regexp.Regexp, err error) {
+	var expr string
+	if expr, err = stringArg(method, vals, i); err != nil {
+		return
+	}
+	return regexp.Compile(expr)
+}
+
+// matchValue the match as a string if the regexp has no capture group, otherwise an array of
+// the match followed by the groups
+func matchValue(reg *regexp.Regexp, match []string) Value {
+	if reg.NumSubexp() == 0 {
+		return StringValue([]byte(match[0])...)
+	}
+	return stringsValue(match)
+}
+
+// findString find(regexp), the first match of the regexp, null if not matched
+func findString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	var vals []Value
+	if vals, err = stringArgs("find", args, ctx, 1, 1); err != nil {
+		return
+	}
+	var reg *regexp.Regexp
+	if reg, err = regexpArg("find", vals, 0); err != nil {
+		return
+	}
+	match := reg.FindStringSubmatch(val.String())
+	if match == nil {
+		return NullValue(), nil
+	}
+	return matchValue(reg, match), nil
+}
+
+// findAllString findAll(regexp) or findAll(regexp, n), all the matches or the first n matches
+func findAllString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	var vals []Value
+	if vals, err = stringArgs("findAll", args, ctx, 1, 2); err != nil {
+		return
+	}
+	var reg *regexp.Regexp
+	if reg, err = regexpArg("findAll", vals, 0); err != nil {
+		return
+	}
+	n := -1
+	if len(vals) == 2 {
+		if n, err = intArg("findAll", vals, 1); err != nil {
+			return
+		}
+	}
+	arr := NewArray()
+	for _, match := range reg.FindAllStringSubmatch(val.String(), n) {
+		arr.items = append(arr.items, matchValue(reg, match))
+	}
+	return ArrayValue(arr), nil
+}
+
+// replaceAllString replaceAll(regexp, repl), $1 or ${name} in repl is the capture group
+func replaceAllString(val Value, args Expr, ctx Context) (ret Value, err error) {
+	var vals []Value
+	if vals, err = stringArgs("replaceAll", args, ctx, 2, 2); err != nil {
+		return
+	}
+	var reg *regexp.Regexp
+	if reg, err = regexpArg("replaceAll", vals, 0); err != nil {
+		return
+	}
+	var repl string
+	if repl, err = stringArg("replaceAll", vals, 1); err != nil {
+		return
+	}
+	return StringValue([]byte(reg.ReplaceAllString(val.String(), repl))...), nil
+}
+
+func stringsValue(ss []string) Value {
+	arr := NewArray()
+	for _, s := range ss {
+		arr.items = append(arr.items, StringValue([]byte(s)...))
+	}
+	return ArrayValue(arr)
+}
@@ -69,3 +69,61 @@ func TestString_sub(t *testing.T) {
 		t.Fatal("match error")
 	}
 }
+
+func TestString_subRunes(t *testing.T) {
+	runCases(t, []stmtCase{
+		{src: `"中文标签".sub([1, 2])`, val: `"文标"`},
+		{src: `"中文标签".sub([2, null])`, val: `"标签"`},
+		{src: `s = "标签: a"; s.sub([0, s.index(":") - 1])`, val: `"标签"`},
+	})
+}
+
+func TestString_methods(t *testing.T) {
+	runCases(t, []stmtCase{
+		{src: `" a b ".trim()`, val: `"a b"`},
+		{src: `"--a--".trim("-")`, val: `"a"`},
+		{src: `" a ".trimLeft()`, val: `"a "`},
+		{src: `"a--".trimRight("-")`, val: `"a"`},
+		{src: `"Ab".upper() + "Ab".lower()`, val: `"ABab"`},
+		{src: `"hello big-world".title()`, val: `"Hello Big-World"`},
+		{src: `"a,b,c".split(",")`, val: `["a","b","c"]`},
+		{src: `"a,b,c".split(",", 2)`, val: `["a","b,c"]`},
+		{src: `"aaa".replace("a", "b", 2)`, val: `"bba"`},
+		{src: `"aaa".replace("a", "b")`, val: `"bbb"`},
+		{src: `["abc".startsWith("ab"), "abc".endsWith("bc"), "abc".contains("x")]`, val: `[true,true,false]`},
+		{src: `"ab".repeat(2)`, val: `"abab"`},
+		{src: `"ab".repeat(-1)`, shoulderr: true},
+		{src: `"xy".repeat(9223372036854775807)`, shoulderr: true},
+		{src: `"".repeat(9223372036854775807)`, val: `""`},
+		{src: `"7".padLeft(3, "0")`, val: `"007"`},
+		{src: `"ab".padRight(5, "-=")`, val: `"ab-=-"`},
+		{src: `"标签".padLeft(3)`, val: `" 标签"`},
+		{src: `"abc".padLeft(2)`, val: `"abc"`},
+		{src: `"ab".padRight(6, "标签x")`, val: `"ab标签x标"`},
+		{src: `"x".padLeft(9223372036854775807, "ab")`, shoulderr: true},
+		{src: `"标签a".len()`, val: `3`},
+		{src: `"%s=%.2f %d %v".format("x", 1.5, 3, [1])`, val: `"x=1.50 3 [1]"`},
+		{src: `"标签ab".reverse()`, val: `"ba签标"`},
+		{src: `"标签".chars()`, val: `["标","签"]`},
+		{src: `"a1b22".find("[0-9]+")`, val: `"1"`},
+		{src: `"k=v".find("(\\w)=(\\w)")`, val: `["k=v","k","v"]`},
+		{src: `"a".find("[0-9]")`, val: `null`},
+		{src: `"a1b22c3".findAll("[0-9]+")`, val: `["1","22","3"]`},
+		{src: `"a1b22c3".findAll("[0-9]+", 2)`, val: `["1","22"]`},
+		{src: `"a=1,b=2".findAll("(\\w)=(\\d)")`, val: `[["a=1","a","1"],["b=2","b","2"]]`},
+		{src: `"a=1,b=2".replaceAll("(\\w)=(\\d)", "$2=$1")`, val: `"1=a,2=b"`},
+		{src: `"a".find("(")`, shoulderr: true},
+		{src: `"a".split(1)`, shoulderr: true},
+		{src: `"a".upper(1)`, shoulderr: true},
+		{src: `"a标签".byteLen()`, val: `7`},
+		{src: `"a标签".byteIndex("签")`, val: `4`},
+		{src: `"a标签".byteSub([1, 3])`, val: `"标"`},
+		{src: `"cafe\u0301".normalize().len()`, val: `4`},
+		{src: `"caf\u00e9".normalize("NFD").len()`, val: `5`},
+		{src: `"\ufb01".normalize("nfkc")`, val: `"fi"`},
+		{src: `"a".normalize("NFX")`, shoulderr: true},
+		{src: `"Straße".fold()`, val: `"strasse"`},
+		{src: `["STRASSE".equalFold("straße"), "cafe\u0301".equalFold("CAF\u00c9"), "a".equalFold("b")]`, val: `[true,true,false]`},
+		{src: `["b" > "a", "\u00e9" > "z", "标" < "签"]`, val: `[true,true,true]`},
+	})
+}
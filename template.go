@@ -0,0 +1,238 @@
+package djson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templatePart a piece of the template string, the literal text or an embedded expression
+type templatePart struct {
+	pos
+	text string
+	expr Expr
+	spec string // the format of the expr, such as .2f
+}
+
+// templateExpr `user-${v.name}-${v.score|.2f}`, the embedded expressions are evaluated and
+// formatted, then joined with the text
+type templateExpr struct {
+	parts []templatePart
+}
+
+func (e *templateExpr) Eval(ctx Context) (ret Value, err error) {
+	var b strings.Builder
+	for _, part := range e.parts {
+		if part.expr == nil {
+			b.WriteString(part.text)
+			continue
+		}
+		var val Value
+		if val, err = part.expr.Eval(ctx); err != nil {
+			return
+		}
+		var s string
+		if s, err = formatValue(val.RealValue(), part.spec); err != nil {
+			err = wrapError(ErrType, part.pos, err)
+			return
+		}
+		b.WriteString(s)
+	}
+	return StringValue([]byte(b.String())...), nil
+}
+
+// parseTemplate split the raw of the template token to the text and the embedded expressions,
+// the expressions are parsed like the stmts of a block, such as ${a = 1; a + 1}
+func parseTemplate(token *Token, opt *option) (ret Expr, err error) {
+	raw := token.Raw
+	// the position of raw[i] in the source, the template begins after the backtick
+	at := func(i int) pos {
+		p := pos{row: token.Row, col: token.Col + 1 + i, span: 1}
+		if nl := bytes.LastIndexByte(raw[:i], '\n'); nl >= 0 {
+			p.row += bytes.Count(raw[:i], []byte{'\n'})
+			p.col = i - nl
+		}
+		return p
+	}
+	e := &templateExpr{}
+	var text []byte
+	textAt := 0
+	flush := func() error {
+		if len(text) == 0 {
+			return nil
+		}
+		s, _, err := unescape(text)
+		if err != nil {
+			return newError(ErrLex, at(textAt), err)
+		}
+		e.parts = append(e.parts, templatePart{text: string(s)})
+		text = nil
+		return nil
+	}
+	for i := 0; i < len(raw); i++ {
+		if len(text) == 0 {
+			textAt = i
+		}
+		if raw[i] == '\\' && i+1 < len(raw) {
+			// \` and \$ are the escapes of the template only, the others are json escapes
+			if raw[i+1] == '`' || raw[i+1] == '$' {
+				text = append(text, raw[i+1])
+			} else {
+				text = append(text, raw[i], raw[i+1])
+			}
+			i++
+			continue
+		}
+		if raw[i] != '$' || i+1 >= len(raw) || raw[i+1] != '{' {
+			text = append(text, raw[i])
+			continue
+		}
+		if err = flush(); err != nil {
+			return
+		}
+		end, bar := closingBrace(raw, i+2)
+		if end < 0 {
+			err = newError(ErrParse, pos{row: at(i).row, col: at(i).col, span: 2}, errors.New("unclosed ${ in the template"))
+			return
+		}
+		src, spec := raw[i+2:end], ""
+		if bar >= 0 {
+			src, spec = raw[i+2:bar], strings.TrimSpace(string(raw[bar+1:end]))
+			if !validSpec(spec) {
+				p := at(bar + 1)
+				p.span = end - bar - 1
+				err = newError(ErrParse, p, fmt.Errorf("invalid format [%s], expect the format like .2f, 05d or x", spec))
+				return
+			}
+		}
+		part := templatePart{pos: at(i), spec: spec}
+		part.span = end - i + 1
+		if part.expr, err = parseEmbedded(src, at(i+2), opt); err != nil {
+			return
+		}
+		e.parts = append(e.parts, part)
+		i = end
+	}
+	if err = flush(); err != nil {
+		return
+	}
+	ret = e
+	return
+}
+
+// parseEmbedded parse the expression embedded in the template, p is the position of src in the source
+func parseEmbedded(src []byte, p pos, opt *option) (ret *blockExpr, err error) {
+	lexer := NewLexer(bytes.NewReader(src), defaultBufSize)
+	lexer.row, lexer.col = p.row, p.col
+	if ret, err = parseBlock(NewTokenScanner(lexer), opt); err != nil {
+		return
+	}
+	if len(ret.stmts) == 0 {
+		p.col -= 2
+		p.span = len(src) + 3
+		err = newError(ErrParse, p, errors.New("empty ${} in the template"))
+	}
+	return
+}
+
+// closingBrace find the } closing the expression begins at from, the braces in the strings and the
+// nested objects are skipped. bar is the index of the | separating the format, -1 if no format
+func closingBrace(raw []byte, from int) (end, bar int) {
+	depth, bar := 0, -1
+	for i := from; i < len(raw); i++ {
+		switch raw[i] {
+		case '"':
+			for i++; i < len(raw) && raw[i] != '"'; i++ {
+				if raw[i] == '\\' {
+					i++
+				}
+			}
+		case '{', '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case '}':
+			if depth == 0 {
+				return i, bar
+			}
+			depth--
+		case '|':
+			// | is the format, || is the logic or
+			if i+1 < len(raw) && raw[i+1] == '|' {
+				i++
+				continue
+			}
+			if depth == 0 && bar < 0 {
+				bar = i
+			}
+		}
+	}
+	return -1, -1
+}
+
+var specPattern = regexp.MustCompile(`^%?[-+# 0]*[0-9]*(\.[0-9]+)?[bcdoxXeEfFgGsqvt]$`)
+
+func validSpec(spec string) bool {
+	return specPattern.MatchString(spec)
+}
+
+// formatValue format the value with the spec, a printf verb and its flags with or without %,
+// such as .2f, 05d, x. the value is formatted as templateString if no spec
+func formatValue(val Value, spec string) (string, error) {
+	if spec == "" {
+		return templateString(val), nil
+	}
+	spec = "%" + strings.TrimPrefix(spec, "%")
+	var arg interface{}
+	var err error
+	switch verb := spec[len(spec)-1]; verb {
+	case 'b', 'c', 'd', 'o', 'x', 'X':
+		if val.Type != ValueInt && val.Type != ValueFloat {
+			return "", fmt.Errorf("can't format [%s] with %%%c, expect a number", val.TypeName(), verb)
+		}
+		arg, err = val.Int()
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		if val.Type != ValueInt && val.Type != ValueFloat {
+			return "", fmt.Errorf("can't format [%s] with %%%c, expect a number", val.TypeName(), verb)
+		}
+		arg, err = val.Float()
+	case 't':
+		if val.Type != ValueBool {
+			return "", fmt.Errorf("can't format [%s] with %%t, expect a bool", val.TypeName())
+		}
+		arg = val.Bool()
+	default:
+		arg = templateString(val)
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(spec, arg), nil
+}
+
+// templateString the text of the value in the template, the floats are formatted as short as
+// possible, such as 1.23 rather than 1.230000, and the objects and the arrays as compact json
+func templateString(val Value) string {
+	switch val.Type {
+	case ValueNull:
+		return "null"
+	case ValueBool:
+		return strconv.FormatBool(val.Bool())
+	case ValueFloat:
+		f, _ := val.Float()
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	case ValueRange:
+		items, _ := itemsOf(val)
+		val = ArrayValue(NewArray(items...))
+		fallthrough
+	case ValueObject, ValueArray:
+		var buf bytes.Buffer
+		if _, err := NewJsonEncoder().Encode(val, &buf); err == nil {
+			return buf.String()
+		}
+	}
+	return val.String()
+}
@@ -0,0 +1,46 @@
+package djson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	runCases(t, []stmtCase{
+		{src: "v = {\"name\": \"ann\"}; i = 1; `user-${v.name}-${i}`", val: `"user-ann-1"`},
+		{src: "`${1 + 2}${\"a\"}`", val: `"3a"`},
+		{src: "f = 1.23; `${f} ${f|.1f} ${f|%6.3f}`", val: `"1.23 1.2  1.230"`},
+		{src: "n = 42; `${n|05d} ${n|x} ${n|.2f} ${n|-4d}|`", val: `"00042 2a 42.00 42  |"`},
+		{src: "`${true|t} ${null} ${[1, \"a\"]} ${ {\"a\": 1} }`", val: `"true null [1,\"a\"] {\"a\":1}"`},
+		{src: "`\\${a} \\`q\\` \\t \\u00e9`", val: `"${a} ` + "`q`" + ` \t é"`},
+		{src: "a = null; `${a ?? \"d\"} ${1 > 0 ? \"y\" : \"n\"} ${false || true}`", val: `"d y true"`},
+		{src: "`${x = 2; x * 3}`", val: `"6"`},
+		{src: "s = \"a|b\"; `${s} ${\"}\"}`", val: `"a|b }"`},
+		{src: "[1 ... 3].map(`item-${v}`)", val: `["item-1","item-2"]`},
+		{src: "``", val: `""`},
+	})
+}
+
+func TestTemplate_error(t *testing.T) {
+	data := []struct {
+		src      string
+		category ErrorCategory
+		row, col int
+	}{
+		{src: "`a ${b|zz}`", category: ErrParse, row: 1, col: 8},
+		{src: "x = 1;\n`a ${x +}`", category: ErrParse, row: 2, col: 8},
+		{src: "`a\n  ${\"s\"|d}`", category: ErrType, row: 2, col: 3},
+		{src: "`a ${}`", category: ErrParse, row: 1, col: 4},
+		{src: "`a ${b`", category: ErrParse, row: 1, col: 4},
+	}
+	for i, item := range data {
+		_, err := evalSource(item.src)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("%d: expect an Error, got %v", i, err)
+		}
+		if e.Category != item.category || e.Row != item.row || e.Col != item.col {
+			t.Fatalf("%d: unexpected error %v at %d:%d", i, e, e.Row, e.Col)
+		}
+	}
+}
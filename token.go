@@ -32,7 +32,7 @@ const (
 	TokenNull                               // null
 	TokenTrue                               // true
 	TokenFalse                              // false
-	TokenReduction                          // => ->
+	TokenReduction                          // =>
 	TokenNumber                             // number
 	TokenString                             // string
 	TokenRange                              // ... // [1 ... 10].map({"key": "" + v + "_x"})
@@ -41,12 +41,23 @@ const (
 	TokenMod                                // %
 	TokenExit                               // exit
 	TokenReturn                             // return
+	TokenArrow                              // ->
+	TokenEmit                               // emit
+	TokenImport                             // import
+	TokenIf                                 // if
+	TokenElse                               // else
+	TokenQuestion                           // ?
+	TokenCoalesce                           // ??
+	TokenOptionalDot                        // ?.
+	TokenTemplate                           // `template ${expr}`
+	TokenRangeInclusive                     // ..=
 )
 
 type Token struct {
 	Type     TokenType
 	Raw      []byte
 	Row, Col int
+	Span     int // bytes of the token in the source
 }
 
 func (t Token) Skip() bool {
@@ -91,5 +102,15 @@ func (t Token) Name() string {
 		TokenRange:            "Range",            // ... // [1 ... 10].map({"key": "" + v + "_x"})
 		TokenIdentifier:       "Identifier",       // identifier
 		TokenWhitespace:       "Whitespace",       // whitespace
+		TokenArrow:            "Arrow",            // ->
+		TokenEmit:             "Emit",             // emit
+		TokenImport:           "Import",           // import
+		TokenIf:               "If",               // if
+		TokenElse:             "Else",             // else
+		TokenQuestion:         "Question",         // ?
+		TokenCoalesce:         "Coalesce",         // ??
+		TokenOptionalDot:      "OptionalDot",      // ?.
+		TokenTemplate:         "Template",         // `template ${expr}`
+		TokenRangeInclusive:   "RangeInclusive",   // ..=
 	}[t.Type]
 }
@@ -0,0 +1,225 @@
+package djson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// TomlError the error returned when a Value can't be expressed in toml, such as a null
+// or a top-level array, nothing is written if it's returned
+type TomlError struct {
+	Path string // dotted path of the value, empty for the top-level value
+	Type string // type name of the value
+}
+
+// Error implements the error
+func (e *TomlError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("can't encode a top-level [%s] to toml, only object is supported", e.Type)
+	}
+	return fmt.Sprintf("can't encode [%s] at [%s] to toml", e.Type, e.Path)
+}
+
+type tomlEncoder struct{}
+
+// NewTomlEncoder new an Encoder encodes the Value to toml, the top-level value must be an object,
+// the nested objects are encoded as the tables with the dotted names, such as [server.http],
+// and the arrays of objects as the arrays of tables, such as [[products]]
+func NewTomlEncoder() *tomlEncoder {
+	return &tomlEncoder{}
+}
+
+// Encode implements the Encoder
+func (te tomlEncoder) Encode(val Value, w io.Writer) (int, error) {
+	val = encodable(val)
+	if val.Type != ValueObject {
+		return 0, &TomlError{Type: val.TypeName()}
+	}
+	var b bytes.Buffer
+	if err := te.table(&b, val.Value.(Object), nil, ""); err != nil {
+		return 0, err
+	}
+	return w.Write(b.Bytes())
+}
+
+// table write the pairs of obj after the header, the key/value pairs are written before the
+// sub tables, as toml requires
+func (te tomlEncoder) table(b *bytes.Buffer, obj Object, path []string, header string) (err error) {
+	var tables []*pair
+	var body bytes.Buffer
+	obj.Each(func(k []byte, val Value) bool {
+		val = encodable(val)
+		if val.Type == ValueObject || isArrayOfTables(val) {
+			tables = append(tables, &pair{key: k, val: val})
+			return true
+		}
+		body.WriteString(tomlKey(string(k)))
+		body.WriteString(" = ")
+		err = te.inline(&body, val, append(path, string(k)))
+		body.WriteByte('\n')
+		return err == nil
+	})
+	if err != nil {
+		return
+	}
+	// the header of a table containing the sub tables only can be omitted
+	if header != "" && (body.Len() > 0 || len(tables) == 0) {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(header)
+		b.WriteByte('\n')
+	}
+	b.Write(body.Bytes())
+	for _, p := range tables {
+		sub := append(append([]string{}, path...), string(p.key))
+		name := tomlPath(sub)
+		if p.val.Type == ValueObject {
+			if err = te.table(b, p.val.Value.(Object), sub, "["+name+"]"); err != nil {
+				return
+			}
+			continue
+		}
+		p.val.Value.(Array).Each(func(_ int, item Value) bool {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString("[[" + name + "]]\n")
+			err = te.table(b, encodable(item).Value.(Object), sub, "")
+			return err == nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// inline write the val as a toml value in a line
+func (te tomlEncoder) inline(b *bytes.Buffer, val Value, path []string) (err error) {
+	val = encodable(val)
+	switch val.Type {
+	case ValueBool, ValueInt:
+		b.Write(val.Value.(Byter).Bytes())
+	case ValueFloat:
+		f, _ := val.Float()
+		b.WriteString(tomlFloat(f))
+	case ValueString:
+		b.WriteString(tomlString(val.String()))
+	case ValueArray:
+		b.WriteByte('[')
+		val.Value.(Array).Each(func(i int, item Value) bool {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			err = te.inline(b, item, append(path, strconv.Itoa(i)))
+			return err == nil
+		})
+		b.WriteByte(']')
+	case ValueObject:
+		b.WriteByte('{')
+		i := 0
+		val.Value.(Object).Each(func(k []byte, item Value) bool {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			i++
+			b.WriteString(" " + tomlKey(string(k)) + " = ")
+			err = te.inline(b, item, append(path, string(k)))
+			return err == nil
+		})
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteByte('}')
+	default:
+		err = &TomlError{Path: strings.Join(path, "."), Type: val.TypeName()}
+	}
+	return
+}
+
+// isArrayOfTables whether the val is a non-empty array of objects
+func isArrayOfTables(val Value) bool {
+	if val.Type != ValueArray || val.Value.(Array).Total() == 0 {
+		return false
+	}
+	ret := true
+	val.Value.(Array).Each(func(_ int, item Value) bool {
+		ret = encodable(item).Type == ValueObject
+		return ret
+	})
+	return ret
+}
+
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = tomlKey(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+func tomlKey(k string) string {
+	if tomlBareKey.MatchString(k) {
+		return k
+	}
+	return tomlString(k)
+}
+
+func tomlFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if strings.Contains(s, ".") {
+		return s
+	}
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		return s[:i] + ".0" + s[i:]
+	}
+	return s + ".0"
+}
+
+// tomlString quote the string as a toml basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
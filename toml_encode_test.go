@@ -0,0 +1,80 @@
+package djson
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func tomlOf(src string) (string, error) {
+	prog, err := Compile(strings.NewReader(src))
+	if err != nil {
+		return "", err
+	}
+	val, err := prog.Eval(nil)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	_, err = NewTomlEncoder().Encode(val, &buf)
+	return buf.String(), err
+}
+
+func TestTomlEncoder(t *testing.T) {
+	out, err := tomlOf(`{
+  "package": {"name": "app", "version": "0.1.0", "authors": ["joe <joe@x.io>"]},
+  "title": "say \"hi\"",
+  "ratio": 2.0,
+  "dependencies": {"serde": {"version": "1", "features": ["derive"]}},
+  "bin": [{"name": "a", "path": {"src": "a.rs"}}, {"name": "b"}],
+  "points": [{"x": 1}, 2],
+  "empty": {},
+  "my key": true
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `title = "say \"hi\""
+ratio = 2.0
+points = [{ x = 1 }, 2]
+"my key" = true
+
+[package]
+name = "app"
+version = "0.1.0"
+authors = ["joe <joe@x.io>"]
+
+[dependencies.serde]
+version = "1"
+features = ["derive"]
+
+[[bin]]
+name = "a"
+
+[bin.path]
+src = "a.rs"
+
+[[bin]]
+name = "b"
+
+[empty]
+`
+	if out != expect {
+		t.Fatalf("toml error:\n%s", out)
+	}
+}
+
+func TestTomlEncoder_error(t *testing.T) {
+	for _, src := range []string{`[1, 2]`, `{"a": {"b": null}}`, `{"a": [1, null]}`} {
+		out, err := tomlOf(src)
+		var te *TomlError
+		if !errors.As(err, &te) || out != "" {
+			t.Fatalf("%s should fail with a TomlError, got %v", src, err)
+		}
+	}
+	_, err := tomlOf(`{"a": {"b": null}}`)
+	if err.Error() != "can't encode [null] at [a.b] to toml" {
+		t.Fatalf("error message error: %s", err)
+	}
+}
@@ -1,11 +1,17 @@
 package djson
 
-import "io"
+import (
+	"context"
+	"errors"
+	"io"
+)
 
 // Translator a translator for translating djson to a json or other result format
 // depending the Encoder,
 type Translator interface {
 	Translate(r io.Reader, w io.Writer) (int, error)
+	TranslateContext(goctx context.Context, r io.Reader, w io.Writer) (int, error)
+	TranslateInto(r io.Reader, v interface{}) error
 }
 
 // Encoder encode the Value that interpeter constructed from djson to a result format
@@ -14,10 +20,30 @@ type Encoder interface {
 	Encode(val Value, w io.Writer) (int, error)
 }
 
+// DocumentEncoder an Encoder which can write many documents to a stream, such as NDJSON
+type DocumentEncoder interface {
+	Encoder
+	// EncodeDocument encode the ith document
+	EncodeDocument(i int, val Value, w io.Writer) (int, error)
+}
+
+// StreamEncoder an Encoder which can encode a top-level array element by element, the result
+// of BeginArray, EncodeElement for each element and EndArray should be same as Encode the array
+type StreamEncoder interface {
+	Encoder
+	BeginArray(w io.Writer) (int, error)
+	EncodeElement(i int, val Value, w io.Writer) (int, error)
+	EndArray(total int, w io.Writer) (int, error)
+}
+
 type translator struct {
-	encoder Encoder
-	bufSize uint
-	ctx     Context
+	encoder   Encoder
+	bufSize   uint
+	ctx       Context
+	stmtOpts  []StmtOption
+	funcs     map[string]Callable
+	streaming bool
+	documents bool
 }
 
 // BufSize set a buffer size for translator
@@ -34,33 +60,159 @@ func Ctx(ctx Context) func(*translator) {
 	}
 }
 
+// StmtOpts set the StmtOptions to compile the source, such as Filename
+func StmtOpts(opts ...StmtOption) func(*translator) {
+	return func(opt *translator) {
+		opt.stmtOpts = append(opt.stmtOpts, opts...)
+	}
+}
+
+// Funcs register the modules to a copy of the Context of translator, the key is the name used in djson,
+// such as Funcs(map[string]Callable{"_json": funcs.NewJson()}) makes _json.decode(s) work
+func Funcs(funcs map[string]Callable) func(*translator) {
+	return func(opt *translator) {
+		if opt.funcs == nil {
+			opt.funcs = make(map[string]Callable, len(funcs))
+		}
+		for name, c := range funcs {
+			opt.funcs[name] = c
+		}
+	}
+}
+
+// Limited evaluate and encode within the Limits
+func Limited(l Limits) func(*translator) {
+	return StmtOpts(WithLimits(l))
+}
+
+// Streaming write the elements of the top-level array built by mapping a range, such as
+// [1 ... 1000000].map({"id": v}), to the writer one by one without holding the array in memory,
+// it takes effect only if the Encoder is a StreamEncoder
+func Streaming() func(*translator) {
+	return func(opt *translator) {
+		opt.streaming = true
+	}
+}
+
+// Documents write each document of the Program, see Program.Documents, instead of the value of
+// the last stmt, it works only if the Encoder is a DocumentEncoder, such as the json and yaml encoders
+func Documents() func(*translator) {
+	return func(opt *translator) {
+		opt.documents = true
+	}
+}
+
 // NewTranslator new a translator
 func NewTranslator(e Encoder, opts ...func(*translator)) *translator {
 	t := &translator{encoder: e}
 	for _, opt := range opts {
 		opt(t)
 	}
+	if len(t.funcs) > 0 {
+		// the modules are registered to a copy, the Context of Ctx is left unchanged
+		if t.ctx == nil {
+			t.ctx = NewContext()
+		} else {
+			t.ctx = t.ctx.Copy()
+		}
+	}
+	for name, c := range t.funcs {
+		t.ctx.RegisterModule(name, c)
+	}
 	return t
 }
 
 // Translate implements ths Translator
 func (t *translator) Translate(r io.Reader, w io.Writer) (int, error) {
-	scanner := NewTokenScanner(NewLexer(r, t.bufSize))
-	if t.ctx == nil {
-		t.ctx = NewContext()
-	}
-	stmt := NewStmtExecutor(scanner, t.ctx)
-	var val Value
-	for {
-		if err := stmt.Execute(); err != nil {
-			return 0, err
+	return t.TranslateContext(context.Background(), r, w)
+}
+
+// TranslateContext implements ths Translator, the evaluation stops with the error of goctx
+// once goctx is done, such as the client disconnected
+func (t *translator) TranslateContext(goctx context.Context, r io.Reader, w io.Writer) (int, error) {
+	prog, err := compile(r, t.bufSize, t.stmtOpts...)
+	if err != nil {
+		return 0, err
+	}
+	return t.TranslateProgramContext(goctx, prog, w)
+}
+
+// TranslateInto implements the Translator, the value is stored in the go value v points to
+// instead of being encoded, see Unmarshal
+func (t *translator) TranslateInto(r io.Reader, v interface{}) error {
+	prog, err := compile(r, t.bufSize, t.stmtOpts...)
+	if err != nil {
+		return err
+	}
+	val, err := prog.Eval(t.ctx)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(val, v)
+}
+
+// TranslateProgram evaluate a compiled Program and encode the value to w
+func (t *translator) TranslateProgram(prog *Program, w io.Writer) (int, error) {
+	return t.TranslateProgramContext(context.Background(), prog, w)
+}
+
+// TranslateProgramContext translate a compiled Program like TranslateContext
+func (t *translator) TranslateProgramContext(goctx context.Context, prog *Program, w io.Writer) (int, error) {
+	if prog.limits != nil && prog.limits.OutputSize > 0 {
+		w = &limitedWriter{w: w, max: prog.limits.OutputSize}
+	}
+	if t.documents {
+		de, ok := t.encoder.(DocumentEncoder)
+		if !ok {
+			return 0, errors.New("the encoder can't write multiple documents")
 		}
-		if stmt.value.Type != ValueNull {
-			val = stmt.value
+		total := 0
+		err := prog.Documents(goctx, t.ctx, func(i int, val Value) error {
+			writes, err := de.EncodeDocument(i, val, w)
+			total += writes
+			return err
+		})
+		return total, err
+	}
+	if se, ok := t.encoder.(StreamEncoder); ok && t.streaming {
+		return t.stream(goctx, prog, se, w)
+	}
+	val, err := prog.EvalContext(goctx, t.ctx)
+	if err != nil {
+		return 0, err
+	}
+	return t.encoder.Encode(val, w)
+}
+
+// stream translate the Program with Program.Stream, the array begins before the first element
+// is written, so nothing is written if the evaluation fails before that
+func (t *translator) stream(goctx context.Context, prog *Program, se StreamEncoder, w io.Writer) (total int, err error) {
+	var writes, count int
+	val, streamed, err := prog.Stream(goctx, t.ctx, func(i int, val Value) (err error) {
+		if i == 0 {
+			if writes, err = se.BeginArray(w); err != nil {
+				return
+			}
+			total += writes
 		}
-		if scanner.EndAt() == TokenEOF {
-			break
+		writes, err = se.EncodeElement(i, val, w)
+		total += writes
+		count++
+		return
+	})
+	if err != nil {
+		return
+	}
+	if !streamed {
+		return se.Encode(val, w)
+	}
+	if count == 0 {
+		if writes, err = se.BeginArray(w); err != nil {
+			return
 		}
+		total += writes
 	}
-	return t.encoder.Encode(val, w)
+	writes, err = se.EndArray(count, w)
+	total += writes
+	return
 }
@@ -2,9 +2,12 @@ package djson
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestTranslator(t *testing.T) {
@@ -97,3 +100,123 @@ func BenchmarkTranslator_full(b *testing.B) {
 		}
 	}
 }
+
+func TestTranslator_funcs(t *testing.T) {
+	greet := NewCallableRegister("greet")
+	greet.RegisterCall("hello", func(_ Value, args Expr, vars Context) (Value, error) {
+		name, err := args.Eval(vars)
+		if err != nil {
+			return NullValue(), err
+		}
+		return StringValue([]byte("hello " + name.String())...), nil
+	})
+	translator := NewTranslator(NewJsonEncoder(), Funcs(map[string]Callable{"_greet": greet}))
+	ob := bytes.Buffer{}
+	if _, err := translator.Translate(bytes.NewBufferString(`user = "joe"; _greet.hello(user)`), &ob); err != nil {
+		t.Fatal(err)
+	}
+	if ob.String() != `"hello joe"` {
+		t.Fatalf("funcs error: %s", ob.String())
+	}
+	vars := NewContext()
+	vars.Assign([]byte("user"), StringValue([]byte("ann")...))
+	translator = NewTranslator(NewJsonEncoder(), Ctx(vars), Funcs(map[string]Callable{"_greet": greet}))
+	ob.Reset()
+	if _, err := translator.Translate(bytes.NewBufferString(`_greet.hello(user)`), &ob); err != nil {
+		t.Fatal(err)
+	}
+	if ob.String() != `"hello ann"` {
+		t.Fatalf("funcs with Ctx error: %s", ob.String())
+	}
+	if v := vars.ValueOf([]byte("_greet")); v.Type != ValueNull {
+		t.Fatalf("funcs should not be registered to the Context of Ctx, got [%s]", v.TypeName())
+	}
+}
+
+func TestTranslator_context(t *testing.T) {
+	type key struct{}
+	tracer := NewCallableRegister("tracer")
+	tracer.RegisterContextCall("id", func(goctx context.Context, _ Value, _ Expr, _ Context) (Value, error) {
+		return StringValue([]byte(goctx.Value(key{}).(string))...), nil
+	})
+	translator := NewTranslator(NewJsonEncoder(), Funcs(map[string]Callable{"_tracer": tracer}))
+	goctx := context.WithValue(context.Background(), key{}, "req-1")
+	ob := bytes.Buffer{}
+	if _, err := translator.TranslateContext(goctx, bytes.NewBufferString(`_tracer.id()`), &ob); err != nil {
+		t.Fatal(err)
+	}
+	if ob.String() != `"req-1"` {
+		t.Fatalf("context callback error: %s", ob.String())
+	}
+	goctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := translator.TranslateContext(goctx, bytes.NewBufferString(`[0 ... 100000000].map(v)`), &ob)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("evaluation should be canceled: %v", err)
+	}
+}
+
+func TestTranslator_streaming(t *testing.T) {
+	sources := []string{
+		`users = [1 ... 5].map({"name": "user" + v, "i": i}).map(v + {"odd": v.i % 2 != 0})`,
+		`[1 ... 3].map("line1\nline2")`,
+		`[1 ... 3].map({"tags": ["a", i], "sub": {"i": i}})`,
+		`[1 ... 1].map(v)`,
+		`a = 1; {"a": a}`,
+		`[1 ... 2].map({"a": v}); true => exit; 1`,
+	}
+	encoders := []Encoder{NewJsonEncoder(), NewJsonEncoder("  "), NewYamlEncoder(), NewYamlEncoder(YamlFlow())}
+	for i, src := range sources {
+		for j, e := range encoders {
+			var expected, ob bytes.Buffer
+			if _, err := NewTranslator(e).Translate(bytes.NewBufferString(src), &expected); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewTranslator(e, Streaming()).Translate(bytes.NewBufferString(src), &ob); err != nil {
+				t.Fatal(err)
+			}
+			if ob.String() != expected.String() {
+				t.Fatalf("streaming error at %d/%d: %q != %q", i, j, ob.String(), expected.String())
+			}
+		}
+	}
+}
+
+func TestTranslator_documents(t *testing.T) {
+	src := `version = "v1"; {"a": 1, "b": [1, 2]}; x = 2; emit {"version": version}; "done"`
+	data := []struct {
+		encoder  Encoder
+		expected string
+	}{
+		{encoder: NewJsonEncoder("  "), expected: "{\"a\":1,\"b\":[1,2]}\n{\"version\":\"v1\"}\n\"done\"\n"},
+		{encoder: NewYamlEncoder(), expected: "a: 1\nb:\n  - 1\n  - 2\n---\nversion: v1\n---\ndone\n"},
+	}
+	for i, item := range data {
+		ob := bytes.Buffer{}
+		if _, err := NewTranslator(item.encoder, Documents()).Translate(bytes.NewBufferString(src), &ob); err != nil {
+			t.Fatal(err)
+		}
+		if ob.String() != item.expected {
+			t.Fatalf("documents error at %d: %q", i, ob.String())
+		}
+	}
+	if _, err := NewTranslator(NewTomlEncoder(), Documents()).Translate(bytes.NewBufferString(src), &bytes.Buffer{}); err == nil {
+		t.Fatal("toml can't write multiple documents")
+	}
+}
+
+func TestTranslator_into(t *testing.T) {
+	var conf struct {
+		Name     string `json:"name"`
+		Replicas []struct {
+			Port int `json:"port"`
+		} `json:"replicas"`
+	}
+	src := `base = 8000; {"name": "app", "replicas": [0 ... 3].map({"port": base + v})}`
+	if err := NewTranslator(NewJsonEncoder()).TranslateInto(bytes.NewBufferString(src), &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Name != "app" || len(conf.Replicas) != 3 || conf.Replicas[2].Port != 8002 {
+		t.Fatalf("translate into error: %+v", conf)
+	}
+}
@@ -76,6 +76,18 @@ func TestFloat_arithmatic(t *testing.T) {
 	}
 }
 
+func TestNumber_compare(t *testing.T) {
+	if c, _ := Float(1.5).Compare(FloatValue(2)); c >= 0 {
+		t.Fatal("1.5 < 2 failed")
+	}
+	if c, _ := Float(2).Compare(FloatValue(1.5)); c <= 0 {
+		t.Fatal("2 > 1.5 failed")
+	}
+	if c, _ := Int(-1 << 63).Compare(IntValue(1)); c >= 0 {
+		t.Fatal("min int < 1 failed")
+	}
+}
+
 func TestString_arithmatic(t *testing.T) {
 	// add
 	a := NewString('h', 'e', 'l', 'l', 'o')
@@ -70,6 +70,8 @@ func (val Value) TypeName() string {
 		ValueInt:        "int",
 		ValueBool:       "bool",
 		ValueIdentifier: "idenfitier",
+		ValueRange:      "range",
+		ValueCallable:   "callable",
 	}[val.Type]
 }
 
@@ -197,7 +199,29 @@ func (val Value) Copy() Value {
 	case ValueObject:
 		return Value{Type: ValueObject, Value: val.Value.(Object).Copy()}
 	case ValueArray:
-		return Value{Type: ValueObject, Value: val.Value.(Array).Copy()}
+		return Value{Type: ValueArray, Value: val.Value.(Array).Copy()}
+	}
+	return val
+}
+
+// deepCopy copy val with the objects and the arrays nested, so the copy can be changed
+// without affecting val
+func deepCopy(val Value) Value {
+	switch val.Type {
+	case ValueObject:
+		var pairs []*pair
+		val.Value.(Object).Each(func(k []byte, v Value) bool {
+			pairs = append(pairs, &pair{key: append([]byte{}, k...), val: deepCopy(v)})
+			return true
+		})
+		return ObjectValue(NewObject(pairs...))
+	case ValueArray:
+		arr := NewArrayWithLength(val.Value.(Array).Total())
+		val.Value.(Array).Each(func(i int, v Value) bool {
+			arr.Set(i, deepCopy(v))
+			return true
+		})
+		return ArrayValue(arr)
 	}
 	return val
 }
@@ -266,7 +290,7 @@ func (left Value) Compare(right Value) (ret int, err error) {
 		err = fmt.Errorf("can't * [%s] with [%s]", rlv.TypeName(), rrv.TypeName())
 		return
 	}
-	return com.Compare(right)
+	return com.Compare(rrv)
 }
 
 func (left Value) Equal(right Value) bool {
@@ -1 +1,14 @@
 package djson
+
+import "testing"
+
+func TestValue_copy(t *testing.T) {
+	src := NewArray(IntValue(1), StringValue([]byte("a")...))
+	val := ArrayValue(src).Copy()
+	if val.Type != ValueArray {
+		t.Fatalf("copy of an array got type [%s]", val.TypeName())
+	}
+	if v := val.Value.(Array).Get(1); !v.Equal(src.Get(1)) {
+		t.Fatalf("copy of an array got %v at 1", v)
+	}
+}
@@ -0,0 +1,365 @@
+package djson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// the plain scalars a yaml 1.1 or 1.2 parser resolves to a non-string value
+	yamlNumber    = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9][0-9_]*(\.[0-9_]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlRadix     = regexp.MustCompile(`^[-+]?0(b[01_]+|o?[0-7_]+|x[0-9a-fA-F_]+)$`)
+	yamlSexagesim = regexp.MustCompile(`^[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+(\.[0-9_]*)?$`)
+	yamlSpecial   = regexp.MustCompile(`^([-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+	yamlTimestamp = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}`)
+	yamlKeywords  = map[string]bool{
+		"": true, "~": true, "null": true, "true": true, "false": true,
+		"yes": true, "no": true, "on": true, "off": true, "y": true, "n": true,
+		"=": true, "<<": true,
+	}
+)
+
+type yamlEncoder struct {
+	indent int
+	flow   bool
+}
+
+// NewYamlEncoder new an Encoder encodes the Value to yaml, the collections are in the block style
+// unless YamlFlow given, the keys of the objects keep the order in the object
+func NewYamlEncoder(opts ...func(*yamlEncoder)) *yamlEncoder {
+	ye := &yamlEncoder{indent: 2}
+	for _, apply := range opts {
+		apply(ye)
+	}
+	return ye
+}
+
+// YamlIndent set the spaces to indent the nested collections, default is 2
+func YamlIndent(indent int) func(*yamlEncoder) {
+	return func(ye *yamlEncoder) {
+		if indent > 0 {
+			ye.indent = indent
+		}
+	}
+}
+
+// YamlFlow encode the collections in the flow style, such as {"a": [1, 2]}
+func YamlFlow() func(*yamlEncoder) {
+	return func(ye *yamlEncoder) {
+		ye.flow = true
+	}
+}
+
+// Encode implements the Encoder
+func (ye yamlEncoder) Encode(val Value, w io.Writer) (int, error) {
+	var b bytes.Buffer
+	if err := ye.encode(&b, val); err != nil {
+		return 0, err
+	}
+	return w.Write(b.Bytes())
+}
+
+// EncodeDocument implements the DocumentEncoder, the documents are separated by ---
+func (ye yamlEncoder) EncodeDocument(i int, val Value, w io.Writer) (int, error) {
+	var b bytes.Buffer
+	if i > 0 {
+		b.WriteString("---\n")
+	}
+	if err := ye.encode(&b, val); err != nil {
+		return 0, err
+	}
+	return w.Write(b.Bytes())
+}
+
+// BeginArray implements the StreamEncoder
+func (ye yamlEncoder) BeginArray(w io.Writer) (int, error) {
+	if ye.flow {
+		return w.Write([]byte{'['})
+	}
+	return 0, nil
+}
+
+// EncodeElement implements the StreamEncoder
+func (ye yamlEncoder) EncodeElement(i int, val Value, w io.Writer) (int, error) {
+	var b bytes.Buffer
+	if ye.flow {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if err := ye.flowNode(&b, val); err != nil {
+			return 0, err
+		}
+		return w.Write(b.Bytes())
+	}
+	b.WriteByte('-')
+	if err := ye.blockValue(&b, encodable(val), 2, false); err != nil {
+		return 0, err
+	}
+	return w.Write(b.Bytes())
+}
+
+// EndArray implements the StreamEncoder
+func (ye yamlEncoder) EndArray(total int, w io.Writer) (int, error) {
+	if ye.flow {
+		return w.Write([]byte("]\n"))
+	}
+	if total == 0 {
+		return w.Write([]byte("[]\n"))
+	}
+	return 0, nil
+}
+
+func (ye yamlEncoder) encode(b *bytes.Buffer, val Value) error {
+	val = encodable(val)
+	if ye.flow {
+		if err := ye.flowNode(b, val); err != nil {
+			return err
+		}
+		b.WriteByte('\n')
+		return nil
+	}
+	switch val.Type {
+	case ValueObject:
+		if val.Value.(Object).Total() > 0 {
+			return ye.mapping(b, val.Value.(Object), 0)
+		}
+	case ValueArray:
+		if val.Value.(Array).Total() > 0 {
+			return ye.sequence(b, val.Value.(Array), 0)
+		}
+	case ValueString:
+		if s := val.String(); literalable(s) {
+			ye.literal(b, s, ye.indent)
+			return nil
+		}
+	}
+	if err := ye.flowNode(b, val); err != nil {
+		return err
+	}
+	b.WriteByte('\n')
+	return nil
+}
+
+// mapping write the pairs of obj in the block style, each line is indented with indent spaces
+func (ye yamlEncoder) mapping(b *bytes.Buffer, obj Object, indent int) (err error) {
+	prefix := strings.Repeat(" ", indent)
+	obj.Each(func(k []byte, val Value) bool {
+		b.WriteString(prefix)
+		b.WriteString(yamlScalar(string(k), false))
+		b.WriteByte(':')
+		err = ye.blockValue(b, encodable(val), indent+ye.indent, true)
+		return err == nil
+	})
+	return
+}
+
+// sequence write the items of arr in the block style, each line is indented with indent spaces
+func (ye yamlEncoder) sequence(b *bytes.Buffer, arr Array, indent int) (err error) {
+	prefix := strings.Repeat(" ", indent)
+	arr.Each(func(_ int, val Value) bool {
+		b.WriteString(prefix)
+		b.WriteByte('-')
+		err = ye.blockValue(b, encodable(val), indent+2, false)
+		return err == nil
+	})
+	return
+}
+
+// blockValue write the val after `key:` or `-`, the nested collections are written on the next lines
+// for the mapping values, and begin on the same line for the sequence items
+func (ye yamlEncoder) blockValue(b *bytes.Buffer, val Value, indent int, inMapping bool) error {
+	var nested bytes.Buffer
+	var err error
+	switch val.Type {
+	case ValueObject:
+		if val.Value.(Object).Total() == 0 {
+			break
+		}
+		err = ye.mapping(&nested, val.Value.(Object), indent)
+	case ValueArray:
+		if val.Value.(Array).Total() == 0 {
+			break
+		}
+		err = ye.sequence(&nested, val.Value.(Array), indent)
+	case ValueString:
+		if s := val.String(); literalable(s) {
+			b.WriteByte(' ')
+			ye.literal(b, s, indent)
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if nested.Len() == 0 {
+		b.WriteByte(' ')
+		if err = ye.flowNode(b, val); err != nil {
+			return err
+		}
+		b.WriteByte('\n')
+		return nil
+	}
+	if inMapping {
+		b.WriteByte('\n')
+		b.Write(nested.Bytes())
+		return nil
+	}
+	// the first line of the item begins after `- `
+	b.WriteByte(' ')
+	b.Write(nested.Bytes()[indent:])
+	return nil
+}
+
+// literal write the multi-line string in the literal block style
+func (ye yamlEncoder) literal(b *bytes.Buffer, s string, indent int) {
+	body := strings.TrimSuffix(s, "\n")
+	switch {
+	case !strings.HasSuffix(s, "\n"):
+		b.WriteString("|-\n")
+	case strings.HasSuffix(body, "\n"):
+		b.WriteString("|+\n")
+	default:
+		b.WriteString("|\n")
+	}
+	prefix := strings.Repeat(" ", indent)
+	for _, line := range strings.Split(body, "\n") {
+		if line != "" {
+			b.WriteString(prefix)
+			b.WriteString(line)
+		}
+		b.WriteByte('\n')
+	}
+}
+
+// flowNode write the val in the flow style
+func (ye yamlEncoder) flowNode(b *bytes.Buffer, val Value) (err error) {
+	val = encodable(val)
+	switch val.Type {
+	case ValueNull:
+		b.WriteString("null")
+	case ValueBool, ValueInt:
+		b.Write(val.Value.(Byter).Bytes())
+	case ValueFloat:
+		f, _ := val.Float()
+		b.WriteString(yamlFloat(f))
+	case ValueString:
+		b.WriteString(yamlScalar(val.String(), ye.flow))
+	case ValueObject:
+		b.WriteByte('{')
+		i := 0
+		val.Value.(Object).Each(func(k []byte, v Value) bool {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			i++
+			b.WriteString(yamlScalar(string(k), true))
+			b.WriteString(": ")
+			err = ye.flowNode(b, v)
+			return err == nil
+		})
+		b.WriteByte('}')
+	case ValueArray:
+		b.WriteByte('[')
+		val.Value.(Array).Each(func(i int, v Value) bool {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			err = ye.flowNode(b, v)
+			return err == nil
+		})
+		b.WriteByte(']')
+	default:
+		err = fmt.Errorf("can't encode [%s] to yaml", val.TypeName())
+	}
+	return
+}
+
+func yamlFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if strings.ContainsAny(s, ".n") {
+		return s
+	}
+	// keep a dot, so yaml 1.1 parsers resolve it as a float too
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		return s[:i] + ".0" + s[i:]
+	}
+	return s + ".0"
+}
+
+// literalable whether the string can be written in the literal block style
+func literalable(s string) bool {
+	if !strings.Contains(s, "\n") || strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\n") {
+		return false
+	}
+	for _, r := range s {
+		if r < 0x20 && r != '\n' && r != '\t' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlScalar write the string as a plain scalar if it can't be mistaken for another type,
+// otherwise as a double quoted scalar
+func yamlScalar(s string, flow bool) string {
+	if yamlPlain(s, flow) {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\x%02x`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func yamlPlain(s string, flow bool) bool {
+	if yamlKeywords[strings.ToLower(s)] || yamlNumber.MatchString(s) || yamlRadix.MatchString(s) ||
+		yamlSexagesim.MatchString(s) || yamlSpecial.MatchString(s) || yamlTimestamp.MatchString(s) {
+		return false
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` \t") || strings.HasSuffix(s, " ") ||
+		strings.HasSuffix(s, ":") || strings.Contains(s, ": ") || strings.Contains(s, " #") {
+		return false
+	}
+	if flow && strings.ContainsAny(s, ",[]{}") {
+		return false
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f || r == 0xfeff {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package djson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func yamlOf(t *testing.T, src string, opts ...func(*yamlEncoder)) string {
+	prog, err := Compile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := prog.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err = NewYamlEncoder(opts...).Encode(val, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestYamlEncoder_block(t *testing.T) {
+	out := yamlOf(t, `{
+  "name": "app",
+  "replicas": 3,
+  "ratio": 1.5,
+  "enabled": true,
+  "nothing": null,
+  "labels": {"tier": "web", "env": "prod"},
+  "ports": [80, 443],
+  "containers": [{"name": "web", "args": ["-v", "run"]}, {"name": "side", "env": {}}],
+  "matrix": [[1, 2], []],
+  "script": "echo hello\necho world\n"
+}`)
+	expect := `name: app
+replicas: 3
+ratio: 1.5
+enabled: true
+nothing: null
+labels:
+  tier: web
+  env: prod
+ports:
+  - 80
+  - 443
+containers:
+  - name: web
+    args:
+      - "-v"
+      - run
+  - name: side
+    env: {}
+matrix:
+  - - 1
+    - 2
+  - []
+script: |
+  echo hello
+  echo world
+`
+	if out != expect {
+		t.Fatalf("block style error:\n%s", out)
+	}
+}
+
+func TestYamlEncoder_scalars(t *testing.T) {
+	out := yamlOf(t, `["yes", "No", "1.0", "007", "0x1F", "1e3", "12:30", "2021-01-02", "", "~", "null",
+"a: b", "#x", "x #y", " lead", "tab\there", "say \"hi\"", "plain text", "中文", 2.0, "a\nb", "a\n\n"]`)
+	expect := `- "yes"
+- "No"
+- "1.0"
+- "007"
+- "0x1F"
+- "1e3"
+- "12:30"
+- "2021-01-02"
+- ""
+- "~"
+- "null"
+- "a: b"
+- "#x"
+- "x #y"
+- " lead"
+- "tab\there"
+- say "hi"
+- plain text
+- 中文
+- 2.0
+- |-
+  a
+  b
+- |+
+  a
+
+`
+	if out != expect {
+		t.Fatalf("scalar quoting error:\n%s", out)
+	}
+}
+
+func TestYamlEncoder_flow(t *testing.T) {
+	out := yamlOf(t, `{"a": [1, "x,y"], "b": {"c": "yes"}, "d": "l1\nl2"}`, YamlFlow())
+	if out != "{a: [1, \"x,y\"], b: {c: \"yes\"}, d: \"l1\\nl2\"}\n" {
+		t.Fatalf("flow style error: %s", out)
+	}
+	if out = yamlOf(t, `"hello"`, YamlIndent(4)); out != "hello\n" {
+		t.Fatalf("top scalar error: %s", out)
+	}
+	if out = yamlOf(t, `{"a": {"b": 1}}`, YamlIndent(4)); out != "a:\n    b: 1\n" {
+		t.Fatalf("indent error: %s", out)
+	}
+}